@@ -0,0 +1,255 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"circuitbreaker/health"
+)
+
+// defaults of AdaptiveLimiterConfig
+const (
+	DefaultInitialLimit = 20
+	DefaultMaxLimit     = 1000
+)
+
+// LimitSample describes the load of an AdaptiveLimiter when a call completes
+type LimitSample struct {
+	// the calls still holding a slot, the completed call has already released its own
+	InFlight int64
+
+	// set when the call failed or timed out
+	Dropped bool
+
+	// the short and long term averages of the latencies reported through AddLatency, zero until one is reported
+	Latency     time.Duration
+	LongLatency time.Duration
+}
+
+// LimitAlgorithm computes the concurrency limit of an AdaptiveLimiter
+type LimitAlgorithm interface {
+	// Update returns the new limit after a call completed. It is bounded by the limiter afterwards
+	Update(limit float64, sample LimitSample) float64
+}
+
+// AIMD increases the limit additively while it is being used and decreases it multiplicatively on failures
+type AIMD struct {
+	// the amount added after a successful call, defaults to 1
+	Increase float64
+
+	// the factor applied after a failed call, defaults to 0.9
+	Backoff float64
+}
+
+// Update ...
+func (a AIMD) Update(limit float64, sample LimitSample) float64 {
+	if sample.Dropped {
+		backoff := a.Backoff
+		if backoff <= 0 || backoff >= 1 {
+			backoff = 0.9
+		}
+		return limit * backoff
+	}
+
+	// a limit that is not being used says nothing about the capacity of the dependency
+	if float64(sample.InFlight)*2 < limit {
+		return limit
+	}
+
+	increase := a.Increase
+	if increase <= 0 {
+		increase = 1
+	}
+	return limit + increase
+}
+
+// Gradient scales the limit by the ratio of the long to the short term latency, so that the limit shrinks as
+// requests start to queue in the dependency and grows by a headroom of √limit while latency is stable
+type Gradient struct {
+	// the ratio by which the short term latency may exceed the long term latency before the limit shrinks,
+	// defaults to 1.5
+	Tolerance float64
+
+	// the weight of the new limit against the current one, defaults to 0.2
+	Smoothing float64
+}
+
+// Update ...
+func (g Gradient) Update(limit float64, sample LimitSample) float64 {
+	tolerance := g.Tolerance
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	smoothing := g.Smoothing
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+
+	gradient := 0.5
+	if !sample.Dropped {
+		if sample.Latency <= 0 || sample.LongLatency <= 0 {
+			return limit
+		}
+		gradient = math.Max(0.5, math.Min(1, tolerance*float64(sample.LongLatency)/float64(sample.Latency)))
+
+		// a limit that is not being used says nothing about the capacity of the dependency
+		if gradient == 1 && float64(sample.InFlight)*2 < limit {
+			return limit
+		}
+	}
+
+	next := limit*gradient + math.Sqrt(limit)
+	return limit*(1-smoothing) + next*smoothing
+}
+
+// AdaptiveLimiterConfig ...
+type AdaptiveLimiterConfig struct {
+	Algorithm LimitAlgorithm
+
+	// the limit before any call completes and its bounds, default to DefaultInitialLimit, 1 and DefaultMaxLimit
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+}
+
+// AdaptiveLimiter is an AdmissionController whose concurrency limit is adjusted by a LimitAlgorithm from the
+// metrics and latencies of the calls it admitted. A circuit breaker reports them through AddMetric and AddLatency
+// along with its Health
+type AdaptiveLimiter struct {
+	mu       sync.Mutex
+	config   AdaptiveLimiterConfig
+	limit    float64
+	inFlight int64
+
+	// exponentially weighted averages of the latency in nanoseconds
+	latency     float64
+	longLatency float64
+}
+
+// weights of a new latency in the short and long term averages
+const (
+	shortLatencyWeight = 0.1
+	longLatencyWeight  = 0.01
+)
+
+// NewAdaptiveLimiter ...
+func NewAdaptiveLimiter(config AdaptiveLimiterConfig) *AdaptiveLimiter {
+	if config.Algorithm == nil {
+		config.Algorithm = AIMD{}
+	}
+	if config.MinLimit < 1 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = DefaultMaxLimit
+	}
+	if config.MaxLimit < config.MinLimit {
+		config.MaxLimit = config.MinLimit
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = DefaultInitialLimit
+	}
+
+	l := &AdaptiveLimiter{config: config}
+	l.limit = l.bound(float64(config.InitialLimit))
+
+	return l
+}
+
+// WithAdaptiveLimit limits the concurrent calls with an AdaptiveLimiter.
+// Calls it rejects are recorded as health.Rejection and answered by the fallback
+func WithAdaptiveLimit(config AdaptiveLimiterConfig) Option {
+	return WithAdmissionController(NewAdaptiveLimiter(config))
+}
+
+// Acquire admits a call while fewer calls than the limit are in flight
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inFlight) >= math.Floor(l.limit) {
+		return nil, &RejectedError{Reason: "concurrency limit reached"}
+	}
+	l.inFlight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// AddMetric updates the limit with the outcome of a call. Rejections and cancellations are ignored
+func (l *AdaptiveLimiter) AddMetric(timestamp time.Time, metricType health.MetricType) error {
+	if !metricType.Valid() {
+		return errors.New("invalid MetricType")
+	}
+	if metricType == health.Rejection || metricType == health.Cancellation {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = l.bound(l.config.Algorithm.Update(l.limit, LimitSample{
+		InFlight:    l.inFlight,
+		Dropped:     metricType != health.Success,
+		Latency:     time.Duration(l.latency),
+		LongLatency: time.Duration(l.longLatency),
+	}))
+
+	return nil
+}
+
+// AddLatency adds a latency to the short and long term averages
+func (l *AdaptiveLimiter) AddLatency(timestamp time.Time, latency time.Duration) error {
+	if latency < 0 {
+		return errors.New("invalid latency")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.longLatency == 0 {
+		l.latency = float64(latency)
+		l.longLatency = float64(latency)
+		return nil
+	}
+
+	l.latency += (float64(latency) - l.latency) * shortLatencyWeight
+	l.longLatency += (float64(latency) - l.longLatency) * longLatencyWeight
+
+	return nil
+}
+
+// Limit returns the current concurrency limit
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// InFlight returns the number of calls holding a slot
+func (l *AdaptiveLimiter) InFlight() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+// Queued always returns 0, calls over the limit are rejected straight away
+func (l *AdaptiveLimiter) Queued() int64 {
+	return 0
+}
+
+// bound clamps a limit between MinLimit and MaxLimit
+func (l *AdaptiveLimiter) bound(limit float64) float64 {
+	return math.Max(float64(l.config.MinLimit), math.Min(float64(l.config.MaxLimit), limit))
+}
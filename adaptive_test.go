@@ -0,0 +1,146 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"circuitbreaker/health"
+)
+
+func TestAIMD_Update(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  float64
+		sample LimitSample
+		want   float64
+	}{
+		{name: "increases a used limit", limit: 10, sample: LimitSample{InFlight: 5}, want: 11},
+		{name: "keeps an unused limit", limit: 10, sample: LimitSample{InFlight: 4}, want: 10},
+		{name: "decreases on failures", limit: 10, sample: LimitSample{InFlight: 1, Dropped: true}, want: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (AIMD{}).Update(tt.limit, tt.sample); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("AIMD.Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradient_Update(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  float64
+		sample LimitSample
+		want   float64
+	}{
+		{
+			name:   "grows by the headroom while latency is stable",
+			limit:  100,
+			sample: LimitSample{InFlight: 80, Latency: 10 * time.Millisecond, LongLatency: 10 * time.Millisecond},
+			want:   102,
+		},
+		{
+			name:   "keeps an unused limit",
+			limit:  100,
+			sample: LimitSample{InFlight: 10, Latency: 10 * time.Millisecond, LongLatency: 10 * time.Millisecond},
+			want:   100,
+		},
+		{
+			name:   "shrinks as latency rises",
+			limit:  100,
+			sample: LimitSample{InFlight: 80, Latency: 30 * time.Millisecond, LongLatency: 10 * time.Millisecond},
+			want:   0.8*100 + 0.2*(50+10),
+		},
+		{
+			name:   "halves on failures",
+			limit:  100,
+			sample: LimitSample{InFlight: 80, Dropped: true},
+			want:   0.8*100 + 0.2*(50+10),
+		},
+		{
+			name:   "keeps the limit without latencies",
+			limit:  100,
+			sample: LimitSample{InFlight: 80},
+			want:   100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Gradient{}).Update(tt.limit, tt.sample); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Gradient.Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	now := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewAdaptiveLimiter(AdaptiveLimiterConfig{InitialLimit: 2, MinLimit: 1, MaxLimit: 3})
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("AdaptiveLimiter.Acquire() error = %v", err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := l.Acquire(context.Background()); err == nil {
+		t.Fatalf("AdaptiveLimiter.Acquire() error = %v over the limit, want %T", err, &RejectedError{})
+	}
+
+	// successes under load raise the limit up to the max
+	for i := 0; i < 5; i++ {
+		l.AddMetric(now, health.Success)
+	}
+	if got := l.Limit(); got != 3 {
+		t.Errorf("AdaptiveLimiter.Limit() = %v, want %v", got, 3)
+	}
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Errorf("AdaptiveLimiter.Acquire() error = %v under the raised limit", err)
+	}
+
+	// failures lower it down to the min
+	for i := 0; i < 50; i++ {
+		l.AddMetric(now, health.Error)
+	}
+	if got := l.Limit(); got != 1 {
+		t.Errorf("AdaptiveLimiter.Limit() = %v, want %v", got, 1)
+	}
+
+	// rejections are not an outcome of the dependency
+	l.AddMetric(now, health.Rejection)
+	if got := l.Limit(); got != 1 {
+		t.Errorf("AdaptiveLimiter.Limit() = %v after a rejection, want %v", got, 1)
+	}
+
+	for _, release := range releases {
+		release()
+		release()
+	}
+	if got := l.InFlight(); got != 1 {
+		t.Errorf("AdaptiveLimiter.InFlight() = %v, want %v", got, 1)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_AdaptiveLimit(t *testing.T) {
+	limiter := NewAdaptiveLimiter(AdaptiveLimiterConfig{Algorithm: AIMD{}, InitialLimit: 10})
+	c, _ := New("test", WithAdmissionController(limiter), WithHealthPolicy(health.PolicyFunc(func(health.Summary) bool {
+		return true
+	})))
+
+	for i := 0; i < 5; i++ {
+		c.DoWithContext(context.Background(), func() (interface{}, error) {
+			return nil, errors.New("failure")
+		})
+	}
+
+	// each failure reported by the breaker lowers the limit by a tenth
+	if got := limiter.Limit(); got != int(10*math.Pow(0.9, 5)) {
+		t.Errorf("AdaptiveLimiter.Limit() = %v, want %v", got, int(10*math.Pow(0.9, 5)))
+	}
+}
@@ -0,0 +1,234 @@
+// Package admin provides an HTTP API to inspect the circuit breakers of a Registry and override their state.
+//
+// Paths are relative to where the handler is mounted, use http.StripPrefix to serve it under a prefix:
+//
+//	GET  /                list every breaker
+//	GET  /{name}          show a breaker
+//	POST /{name}/open     force the breaker open until the override is cleared
+//	POST /{name}/closed   force the breaker closed until the override is cleared
+//	POST /{name}/disable  run every call without recording metrics until the override is cleared
+//	POST /{name}/reset    discard the metrics in the health window
+//	POST /{name}/clear    clear an override, returning the breaker to automatic operation
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"circuitbreaker"
+)
+
+// Handler serves the admin API of a Registry
+type Handler struct {
+	registry  *circuitbreaker.Registry
+	authorize func(r *http.Request) bool
+}
+
+// Option ...
+type Option func(*Handler)
+
+// WithAuth rejects requests with 401 Unauthorized unless authorize returns true
+func WithAuth(authorize func(r *http.Request) bool) Option {
+	return func(h *Handler) {
+		h.authorize = authorize
+	}
+}
+
+// New ...
+func New(registry *circuitbreaker.Registry, opts ...Option) *Handler {
+	h := &Handler{
+		registry: registry,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Breaker is the JSON representation of a circuit breaker
+type Breaker struct {
+	Name    string    `json:"name"`
+	Status  string    `json:"status"`
+	Updated time.Time `json:"updated"`
+	Config  Config    `json:"config"`
+
+	// the time an open breaker waits before probing, including backoff and jitter
+	SleepWindow string `json:"current_sleep_window"`
+
+	// the fraction of calls admitted, below 1 while the breaker ramps up after closing
+	RampUp float64 `json:"ramp_up"`
+
+	// the calls holding and waiting for a slot of the admission controller
+	InFlight int64 `json:"in_flight"`
+	Queued   int64 `json:"queued"`
+
+	Stats Stats `json:"stats"`
+
+	// only set for a breaker in shadow mode
+	Shadow      bool  `json:"shadow,omitempty"`
+	WouldReject int64 `json:"would_reject,omitempty"`
+}
+
+// Config uses the keys of configuration files
+type Config struct {
+	SleepWindow    string  `json:"sleep_window"`
+	Multiplier     float64 `json:"sleep_window_multiplier"`
+	MaxSleepWindow string  `json:"max_sleep_window"`
+	Jitter         float64 `json:"sleep_window_jitter"`
+	Window         string  `json:"window"`
+	BucketSize     string  `json:"bucket_size"`
+	ErrorThreshold float64 `json:"error_threshold"`
+	Timeout        string  `json:"timeout"`
+	RampUp         string  `json:"ramp_up"`
+	RampUpCurve    string  `json:"ramp_up_curve"`
+}
+
+// Stats summarises the health window
+type Stats struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Successes       int64     `json:"successes"`
+	Errors          int64     `json:"errors"`
+	Timeouts        int64     `json:"timeouts"`
+	Rejections      int64     `json:"rejections"`
+	Cancellations   int64     `json:"cancellations"`
+	ErrorPercentage float64   `json:"error_percentage"`
+	MeanLatency     string    `json:"mean_latency"`
+	MaxLatency      string    `json:"max_latency"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// actions are the POST endpoints of a breaker
+var actions = map[string]func(c *circuitbreaker.CircuitBreaker) error{
+	"open": func(c *circuitbreaker.CircuitBreaker) error {
+		return c.SetStatus(circuitbreaker.ForcedOpen)
+	},
+	"closed": func(c *circuitbreaker.CircuitBreaker) error {
+		return c.SetStatus(circuitbreaker.ForcedClosed)
+	},
+	"disable": func(c *circuitbreaker.CircuitBreaker) error {
+		return c.SetStatus(circuitbreaker.Disabled)
+	},
+	"reset": func(c *circuitbreaker.CircuitBreaker) error {
+		c.ResetHealth()
+		return nil
+	},
+	"clear": func(c *circuitbreaker.CircuitBreaker) error {
+		c.Release()
+		return nil
+	},
+}
+
+// ServeHTTP ...
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize != nil && !h.authorize(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		if path == "" {
+			h.list(w)
+			return
+		}
+		c, ok := h.registry.Get(path)
+		if !ok {
+			writeError(w, http.StatusNotFound, "circuit breaker "+path+" not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, breaker(c.Snapshot()))
+
+	case http.MethodPost:
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "unknown action")
+			return
+		}
+		action, ok := actions[path[i+1:]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "unknown action "+path[i+1:])
+			return
+		}
+		c, ok := h.registry.Get(path[:i])
+		if !ok {
+			writeError(w, http.StatusNotFound, "circuit breaker "+path[:i]+" not found")
+			return
+		}
+		if err := action(c); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, breaker(c.Snapshot()))
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *Handler) list(w http.ResponseWriter) {
+	breakers := []Breaker{}
+	for _, c := range h.registry.List() {
+		breakers = append(breakers, breaker(c.Snapshot()))
+	}
+	writeJSON(w, http.StatusOK, breakers)
+}
+
+// breaker converts a snapshot to its JSON representation
+func breaker(s circuitbreaker.Snapshot) Breaker {
+	return Breaker{
+		Name:    s.Name,
+		Status:  s.State.Status().String(),
+		Updated: s.State.Updated(),
+		Config: Config{
+			SleepWindow:    s.Config.EffectiveSleepWindow().String(),
+			Multiplier:     s.Config.SleepWindowMultiplier,
+			MaxSleepWindow: s.Config.MaxSleepWindow.String(),
+			Jitter:         s.Config.SleepWindowJitter,
+			Window:         s.Config.EffectiveHealthMetricsWindow().String(),
+			BucketSize:     s.Config.HealthMetricsBucketSize.String(),
+			ErrorThreshold: s.Config.HealthErrorPercentageThreshold,
+			Timeout:        s.Config.Timeout.String(),
+			RampUp:         s.Config.RampUpDuration.String(),
+			RampUpCurve:    s.Config.RampUpCurve.String(),
+		},
+		SleepWindow: s.SleepWindow.String(),
+		RampUp:      s.RampUp,
+		InFlight:    s.InFlight,
+		Queued:      s.Queued,
+		Stats: Stats{
+			Start:           s.Stats.Start,
+			End:             s.Stats.End,
+			Successes:       s.Stats.Successes,
+			Errors:          s.Stats.Errors,
+			Timeouts:        s.Stats.Timeouts,
+			Rejections:      s.Stats.Rejections,
+			Cancellations:   s.Stats.Cancellations,
+			ErrorPercentage: s.Stats.ErrorPercentage(),
+			MeanLatency:     s.Stats.MeanLatency().String(),
+			MaxLatency:      s.Stats.LatencyMax.String(),
+		},
+		Shadow:      s.Shadow,
+		WouldReject: s.WouldReject,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
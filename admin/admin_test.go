@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"circuitbreaker"
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestHandler(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	registry := circuitbreaker.NewRegistry()
+	for _, name := range []string{"search", "payments/api"} {
+		c, _ := circuitbreaker.New(name, circuitbreaker.WithClock(clock))
+		registry.Register(c)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "detail", method: http.MethodGet, path: "/search", wantCode: http.StatusOK, wantStatus: "closed"},
+		{name: "open", method: http.MethodPost, path: "/payments/api/open", wantCode: http.StatusOK, wantStatus: "forced-open"},
+		{name: "detail after open", method: http.MethodGet, path: "/payments/api", wantCode: http.StatusOK, wantStatus: "forced-open"},
+		{name: "clear", method: http.MethodPost, path: "/payments/api/clear", wantCode: http.StatusOK, wantStatus: "closed"},
+		{name: "closed", method: http.MethodPost, path: "/search/closed", wantCode: http.StatusOK, wantStatus: "forced-closed"},
+		{name: "disable", method: http.MethodPost, path: "/search/disable", wantCode: http.StatusOK, wantStatus: "disabled"},
+		{name: "reset", method: http.MethodPost, path: "/search/reset", wantCode: http.StatusOK, wantStatus: "disabled"},
+		{name: "missing breaker", method: http.MethodGet, path: "/missing", wantCode: http.StatusNotFound},
+		{name: "missing action", method: http.MethodPost, path: "/search/restart", wantCode: http.StatusNotFound},
+		{name: "no action", method: http.MethodPost, path: "/search", wantCode: http.StatusNotFound},
+		{name: "method", method: http.MethodDelete, path: "/search", wantCode: http.StatusMethodNotAllowed},
+	}
+
+	h := New(registry)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Handler.ServeHTTP() code = %v, want %v: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+
+			var got Breaker
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("Handler.ServeHTTP() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandler_list(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	registry := circuitbreaker.NewRegistry()
+	c, _ := circuitbreaker.New("search", circuitbreaker.WithClock(clock), circuitbreaker.WithTimeout(time.Second))
+	registry.Register(c)
+
+	w := httptest.NewRecorder()
+	New(registry).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var got []Breaker
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Breaker{
+		{
+			Name:    "search",
+			Status:  "closed",
+			Updated: clock.Now(),
+			Config: Config{
+				SleepWindow:    "5s",
+				MaxSleepWindow: "0s",
+				Window:         "10s",
+				BucketSize:     "0s",
+				ErrorThreshold: 0.5,
+				Timeout:        "1s",
+				RampUp:         "0s",
+				RampUpCurve:    "linear",
+			},
+			SleepWindow: "5s",
+			RampUp:      1,
+			Stats: Stats{
+				Start:       clock.Now().Add(-9 * time.Second),
+				End:         clock.Now(),
+				MeanLatency: "0s",
+				MaxLatency:  "0s",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Handler.ServeHTTP() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandler_auth(t *testing.T) {
+	registry := circuitbreaker.NewRegistry()
+	c, _ := circuitbreaker.New("search")
+	registry.Register(c)
+
+	h := New(registry, WithAuth(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer secret"
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/search/open", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Handler.ServeHTTP() code = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+	if got := c.Status(); got != circuitbreaker.Closed {
+		t.Errorf("Handler.ServeHTTP() status = %v after an unauthorized request, want %v", got, circuitbreaker.Closed)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/search/open", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Handler.ServeHTTP() code = %v, want %v", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,74 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+)
+
+// AdmissionController limits the calls a circuit breaker runs while the circuit admits them
+type AdmissionController interface {
+	// Acquire admits a call, waiting no longer than ctx allows. The returned release func must be called once
+	// the call has completed. A RejectedError is returned when the call is not admitted
+	Acquire(ctx context.Context) (release func(), err error)
+}
+
+// AdmissionStats is implemented by AdmissionControllers that report their load
+type AdmissionStats interface {
+	InFlight() int64
+	Queued() int64
+}
+
+// RejectedError is returned by an AdmissionController that does not admit a call
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("call rejected: %s", e.Reason)
+}
+
+// WithAdmissionController limits calls with ac. Calls it rejects are recorded as health.Rejection
+// and answered by the fallback. Several admission controllers are acquired in the order they are given
+func WithAdmissionController(ac AdmissionController) Option {
+	return func(c *CircuitBreaker) {
+		c.admissionBuilders = append(c.admissionBuilders, func(*CircuitBreaker) AdmissionController {
+			return ac
+		})
+	}
+}
+
+// acquire takes a slot from every admission controller, releasing the slots taken when one rejects the call
+func (c *CircuitBreaker) acquire(ctx context.Context) (func(), error) {
+	if len(c.admission) == 0 {
+		return func() {}, nil
+	}
+
+	releases := make([]func(), 0, len(c.admission))
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, ac := range c.admission {
+		r, err := ac.Acquire(ctx)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+
+	return release, nil
+}
+
+// admissionStats adds up the load reported by the admission controllers
+func (c *CircuitBreaker) admissionStats() (inFlight, queued int64) {
+	for _, ac := range c.admission {
+		if stats, ok := ac.(AdmissionStats); ok {
+			inFlight += stats.InFlight()
+			queued += stats.Queued()
+		}
+	}
+	return inFlight, queued
+}
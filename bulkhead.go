@@ -0,0 +1,82 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Bulkhead is an AdmissionController that caps the number of concurrent calls,
+// optionally letting a bounded number of calls wait for a slot
+type Bulkhead struct {
+	slots    chan struct{}
+	maxQueue int64
+
+	inFlight int64
+	queued   int64
+}
+
+// NewBulkhead ...
+func NewBulkhead(maxConcurrent, maxQueue int) *Bulkhead {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	if maxQueue < 0 {
+		maxQueue = 0
+	}
+
+	return &Bulkhead{
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: int64(maxQueue),
+	}
+}
+
+// WithBulkhead caps the concurrent calls at maxConcurrent, letting up to maxQueue more calls wait for a slot
+// until their context is done. An operation abandoned at the timeout keeps its slot until it returns
+func WithBulkhead(maxConcurrent, maxQueue int) Option {
+	return WithAdmissionController(NewBulkhead(maxConcurrent, maxQueue))
+}
+
+// Acquire ...
+func (b *Bulkhead) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.acquired(), nil
+	default:
+	}
+
+	if atomic.AddInt64(&b.queued, 1) > b.maxQueue {
+		atomic.AddInt64(&b.queued, -1)
+		return nil, &RejectedError{Reason: "bulkhead is full"}
+	}
+	defer atomic.AddInt64(&b.queued, -1)
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.acquired(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acquired counts a call holding a slot and returns the func releasing it
+func (b *Bulkhead) acquired() func() {
+	atomic.AddInt64(&b.inFlight, 1)
+
+	var released int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&released, 0, 1) {
+			atomic.AddInt64(&b.inFlight, -1)
+			<-b.slots
+		}
+	}
+}
+
+// InFlight returns the number of calls holding a slot
+func (b *Bulkhead) InFlight() int64 {
+	return atomic.LoadInt64(&b.inFlight)
+}
+
+// Queued returns the number of calls waiting for a slot
+func (b *Bulkhead) Queued() int64 {
+	return atomic.LoadInt64(&b.queued)
+}
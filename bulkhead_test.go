@@ -0,0 +1,160 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+	"circuitbreaker/health"
+)
+
+func TestBulkhead_Acquire(t *testing.T) {
+	b := NewBulkhead(2, 1)
+
+	first, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Bulkhead.Acquire() error = %v", err)
+	}
+	if _, err := b.Acquire(context.Background()); err != nil {
+		t.Fatalf("Bulkhead.Acquire() error = %v", err)
+	}
+	if got := b.InFlight(); got != 2 {
+		t.Errorf("Bulkhead.InFlight() = %v, want %v", got, 2)
+	}
+
+	// the third call waits for a slot
+	acquired := make(chan error)
+	go func() {
+		release, err := b.Acquire(context.Background())
+		if err == nil {
+			defer release()
+		}
+		acquired <- err
+	}()
+	for b.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the queue is full
+	if _, err := b.Acquire(context.Background()); err == nil {
+		t.Errorf("Bulkhead.Acquire() error = %v, want %T", err, &RejectedError{})
+	} else if _, ok := err.(*RejectedError); !ok {
+		t.Errorf("Bulkhead.Acquire() error = %v, want %T", err, &RejectedError{})
+	}
+
+	// releasing twice frees a single slot
+	first()
+	first()
+	if err := <-acquired; err != nil {
+		t.Fatalf("Bulkhead.Acquire() error = %v after a release", err)
+	}
+	if got := b.Queued(); got != 0 {
+		t.Errorf("Bulkhead.Queued() = %v, want %v", got, 0)
+	}
+}
+
+func TestBulkhead_Acquire_Cancelled(t *testing.T) {
+	b := NewBulkhead(1, 1)
+	b.Acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Bulkhead.Acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := b.Queued(); got != 0 {
+		t.Errorf("Bulkhead.Queued() = %v, want %v", got, 0)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Bulkhead(t *testing.T) {
+	c, _ := New("test", WithBulkhead(1, 0), WithFallback(func() (interface{}, error) {
+		return 5, nil
+	}))
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan interface{})
+	go func() {
+		got, _ := c.DoWithContext(context.Background(), func() (interface{}, error) {
+			close(running)
+			<-release
+			return 100, nil
+		})
+		done <- got
+	}()
+	<-running
+
+	if got := c.Snapshot().InFlight; got != 1 {
+		t.Errorf("CircuitBreaker.Snapshot() in flight = %v, want %v", got, 1)
+	}
+
+	got, err := c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return 100, nil
+	})
+	if err != nil || got != 5 {
+		t.Errorf("CircuitBreaker.DoWithContext() = %v, %v, want the fallback %v", got, err, 5)
+	}
+
+	close(release)
+	if got := <-done; got != 100 {
+		t.Errorf("CircuitBreaker.DoWithContext() = %v, want %v", got, 100)
+	}
+
+	stats := c.health.Stats()
+	if stats.Rejections != 1 || stats.Successes != 1 {
+		t.Errorf("CircuitBreaker.DoWithContext() stats = %+v, want 1 rejection and 1 success", stats)
+	}
+	if got := c.Snapshot().InFlight; got != 0 {
+		t.Errorf("CircuitBreaker.Snapshot() in flight = %v, want %v", got, 0)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Bulkhead_Abandoned(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithBulkhead(1, 0), WithTimeout(10*time.Millisecond), WithClock(clock),
+		WithHealthPolicy(health.PolicyFunc(func(health.Summary) bool {
+			return true
+		})),
+	)
+
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := c.DoWithContext(context.Background(), func() (interface{}, error) {
+			<-release
+			return 100, nil
+		})
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Millisecond)
+
+	if err := <-done; err == nil {
+		t.Fatalf("CircuitBreaker.DoWithContext() error = %v, want %T", err, &TimeoutError{})
+	}
+
+	// the abandoned operation is still running and keeps its slot
+	if got := c.Snapshot().InFlight; got != 1 {
+		t.Errorf("CircuitBreaker.Snapshot() in flight = %v after a timeout, want %v", got, 1)
+	}
+	calls := 0
+	operation := func() (interface{}, error) {
+		calls++
+		return 100, nil
+	}
+	if _, err := c.DoWithContext(context.Background(), operation); err == nil || calls != 0 {
+		t.Errorf("CircuitBreaker.DoWithContext() = %v, calls %v, want a rejection", err, calls)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for c.Snapshot().InFlight != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got, err := c.DoWithContext(context.Background(), operation); err != nil || got != 100 {
+		t.Errorf("CircuitBreaker.DoWithContext() = %v, %v once the operation returned, want %v", got, err, 100)
+	}
+}
@@ -3,15 +3,32 @@ package circuitbreaker
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"circuitbreaker/internal/health"
+	"circuitbreaker/clock"
+	"circuitbreaker/health"
 )
 
 // Health ...
 type Health interface {
 	Healthy() bool
 	AddMetric(timestamp time.Time, metricType health.MetricType) error
+	Stats() health.Summary
+	Reset()
+}
+
+// MetricRecorder is implemented by AdmissionControllers that learn from the outcome of the calls
+type MetricRecorder interface {
+	AddMetric(timestamp time.Time, metricType health.MetricType) error
+}
+
+// LatencyRecorder is implemented by Health implementations and AdmissionControllers that also track operation latency
+type LatencyRecorder interface {
+	AddLatency(timestamp time.Time, latency time.Duration) error
 }
 
 // CircuitOpenError ...
@@ -22,6 +39,15 @@ func (e *CircuitOpenError) Error() string {
 	return "circuit is open"
 }
 
+// TimeoutError is returned when an operation does not complete within Config.Timeout
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("operation timed out after %v", e.Timeout)
+}
+
 // Status ...
 type Status int64
 
@@ -30,11 +56,42 @@ const (
 	Open Status = iota + 1
 	HalfOpen
 	Closed
+
+	// the forced states pin the circuit until Release is called, regardless of health
+	ForcedOpen
+	ForcedClosed
+
+	// Disabled runs every call without recording metrics until Release is called
+	Disabled
 )
 
 // Valid determines whether the value of a State is valid
 func (s *Status) Valid() bool {
-	return *s >= 1 && *s <= 3
+	return *s >= 1 && *s <= 6
+}
+
+// Pinned determines whether the status is an override that only Release undoes
+func (s Status) Pinned() bool {
+	return s == ForcedOpen || s == ForcedClosed || s == Disabled
+}
+
+// String ...
+func (s Status) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	case Closed:
+		return "closed"
+	case ForcedOpen:
+		return "forced-open"
+	case ForcedClosed:
+		return "forced-closed"
+	case Disabled:
+		return "disabled"
+	}
+	return fmt.Sprintf("Status(%d)", int64(s))
 }
 
 // State ...
@@ -43,122 +100,462 @@ type State struct {
 	updated time.Time
 }
 
-// Config ...
-type Config struct {
-	// the length of time in milliseconds to wait before retrying when the circuit is open
-	SleepWindowMillisenconds int64
+// Status ...
+func (s State) Status() Status {
+	return s.status
+}
 
-	// the size of the in-memory metrics window
-	HealthMetricsWindowSize int64
+// Updated returns the time of the last status change
+func (s State) Updated() time.Time {
+	return s.updated
+}
 
-	// the error percentage threshold determining whether a system is healthy
-	HealthErrorPercentageThreshold float64
+// Reconfigurable is implemented by Health implementations that can apply a new Config without losing their metrics
+type Reconfigurable interface {
+	Reconfigure(config health.Config)
 }
 
 // CircuitBreaker ...
 type CircuitBreaker struct {
+	// mu guards the state and config
+	mu        sync.Mutex
+	name      string
 	state     State
 	config    Config
 	health    Health
 	stateChan chan State
+	eventChan chan Event
 	fallback  func() (interface{}, error)
+	policy    health.HealthPolicy
+	ewma      *health.EWMAConfig
+	envPrefix string
+
+	// a shadow circuit never rejects a call, it only counts the calls it would have rejected
+	shadow      bool
+	wouldReject int64
+
+	// the number of HalfOpen probes that failed since the circuit last closed, and the random
+	// fraction applied to the current sleep window
+	probeFailures int
+	jitter        float64
+
+	// the time a successful probe closed the circuit, starting the ramp up
+	closed time.Time
+
+	// draws the jitter and the calls admitted by the ramp up, guarded by mu
+	rand *rand.Rand
+
+	prober *prober
+
+	// admission controllers are built once the clock is known
+	admissionBuilders []func(c *CircuitBreaker) AdmissionController
+	admission         []AdmissionController
 }
 
 // New ...
-func New(
-	config Config,
-	ch chan State,
-	fallback func() (interface{}, error),
-	healthy func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool,
-) *CircuitBreaker {
-
-	if fallback == nil {
-		fallback = defaultFallback
-	}
-
-	return &CircuitBreaker{
-		config: config,
-		health: health.New(
-			health.Config{
-				WindowSize:               config.HealthMetricsWindowSize,
-				ErrorPercentageThreshold: config.HealthErrorPercentageThreshold,
-			},
-			healthy,
-		),
-		fallback: fallback,
-		state: State{
-			status:  Closed,
-			updated: time.Now(),
-		},
-		stateChan: ch,
-	}
-}
-
-// DoWithContext ...
+func New(name string, opts ...Option) (*CircuitBreaker, error) {
+
+	if name == "" {
+		return nil, errors.New("name must not be empty")
+	}
+
+	c := &CircuitBreaker{
+		name:   name,
+		config: DefaultConfig(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := c.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if c.fallback == nil {
+		c.fallback = defaultFallback
+	}
+
+	if c.config.Clock == nil {
+		c.config.Clock = clock.New()
+	}
+
+	// every instance needs its own sequence for the jitter to spread their probes
+	if c.rand == nil {
+		c.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	if c.health == nil && c.ewma != nil {
+		config := *c.ewma
+		config.ErrorPercentageThreshold = c.config.HealthErrorPercentageThreshold
+		config.Clock = c.config.Clock
+		c.health = health.NewEWMA(config)
+	}
+
+	if c.health == nil {
+		c.health = health.New(c.healthConfig(), c.policy)
+	}
+
+	c.state = State{
+		status:  Closed,
+		updated: c.config.Clock.Now(),
+	}
+
+	for _, build := range c.admissionBuilders {
+		c.admission = append(c.admission, build(c))
+	}
+
+	if c.prober != nil {
+		if err := c.startProbes(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Name ...
+func (c *CircuitBreaker) Name() string {
+	return c.name
+}
+
+// healthConfig returns the health window settings of the config
+func (c *CircuitBreaker) healthConfig() health.Config {
+	return health.Config{
+		WindowSize:               c.config.EffectiveHealthMetricsWindow(),
+		BucketSize:               c.config.HealthMetricsBucketSize,
+		ErrorPercentageThreshold: c.config.HealthErrorPercentageThreshold,
+		Clock:                    c.config.Clock,
+	}
+}
+
+// DoWithContext runs operation when the circuit admits it and calls the fallback otherwise.
+// With a Timeout in the config, an operation still running when the timeout elapses or ctx is done is abandoned:
+// DoWithContext returns at once, but the operation is not stopped and keeps running until it returns
 func (c *CircuitBreaker) DoWithContext(ctx context.Context, operation func() (interface{}, error)) (interface{}, error) {
+	return c.do(ctx, operation, c.fallback)
+}
+
+// do is DoWithContext calling fallback for the calls the circuit does not admit
+func (c *CircuitBreaker) do(ctx context.Context, operation func() (interface{}, error), fallback func() (interface{}, error)) (interface{}, error) {
+
+	c.mu.Lock()
+	now := c.config.Clock.Now()
+	admitted := c.admit(now)
+	ramping := c.rampUp(now) < 1
+	state := c.state
+	config := c.config
+	c.mu.Unlock()
+
+	if !admitted && !c.shadow {
+		return fallback()
+	}
+
+	if state.status == Disabled {
+		return c.execute(ctx, config, operation, func() {})
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if err != nil && !c.shadow {
+		c.addMetric(now, health.Rejection)
+		return fallback()
+	}
+
+	// in shadow mode the call runs anyway
+	if !admitted || err != nil {
+		atomic.AddInt64(&c.wouldReject, 1)
+	}
+	if err != nil {
+		release = func() {}
+	}
+
+	// time spent waiting for the admission controller is not part of the latency
+	start := config.Clock.Now()
+	result, err := c.execute(ctx, config, operation, release)
+
+	// an operation failing after its caller gave up reached the dependency, but says nothing about its health
+	if err != nil && ctx.Err() != nil {
+		c.addMetric(now, health.Cancellation)
+		return result, err
+	}
+
+	c.addLatency(now, config.Clock.Now().Sub(start))
+
+	metricType := health.Success
+	if _, ok := err.(*TimeoutError); ok {
+		metricType = health.Timeout
+	} else if err != nil {
+		metricType = health.Error
+	}
+	c.addMetric(now, metricType)
+
+	// a failure while ramping up means the dependency has not recovered after all
+	switch {
+	case state.status == HalfOpen && c.prober == nil:
+		c.probed(state, metricType == health.Success)
+	case ramping && metricType != health.Success:
+		c.probed(state, false)
+	}
+
+	return result, err
+}
+
+// probed closes a HalfOpen circuit after a successful call and opens it again after a failure.
+// A failure grows the next sleep window, see Config.BackoffSleepWindow, and a success discards the metrics that
+// opened the circuit. The result is ignored when the state has changed since the call was admitted
+func (c *CircuitBreaker) probed(state State, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != state {
+		return
+	}
+
+	if !success {
+		c.setStatus(Open)
+		return
+	}
+
+	// the failures that opened the circuit would open it again straight away
+	c.health.Reset()
+	c.setStatus(Closed)
+	c.closed = c.state.updated
+}
+
+// rampUp returns the fraction of calls the circuit admits, below 1 while it ramps up after closing. c.mu must be held
+func (c *CircuitBreaker) rampUp(now time.Time) float64 {
+	if c.state.status != Closed || c.closed.IsZero() || c.config.RampUpDuration <= 0 {
+		return 1
+	}
+
+	elapsed := now.Sub(c.closed)
+	if elapsed >= c.config.RampUpDuration {
+		return 1
+	}
+
+	return c.config.RampUpCurve.fraction(float64(elapsed) / float64(c.config.RampUpDuration))
+}
+
+// admit determines whether a call may run, moving the circuit between states as it goes. c.mu must be held
+func (c *CircuitBreaker) admit(now time.Time) bool {
+
+	switch c.state.status {
+	case ForcedOpen:
+		return false
+	case ForcedClosed, Disabled:
+		return true
+	}
 
-	now := time.Now()
+	// with a prober only the probes move an open circuit, user calls are rejected until it closes
+	if c.prober != nil && (c.state.status == Open || c.state.status == HalfOpen) {
+		return false
+	}
 
 	// fail immediately and call fallback
-	if c.Status() == Open && nanoToMilli(now.UnixNano()-c.state.updated.UnixNano()) < c.config.SleepWindowMillisenconds {
-		return c.fallback()
+	if c.state.status == Open && now.Sub(c.state.updated) < c.sleepWindow() {
+		return false
 	}
 
 	// the sleep window has elapsed
-	if c.Status() == Open {
-		c.SetStatus(HalfOpen)
+	if c.state.status == Open {
+		c.setStatus(HalfOpen)
 	}
 
 	// if the service is now unhealthy, set the status to Open and call the fallback. HalfOpen status is exempt
-	if !c.health.Healthy() && c.Status() == Closed {
-		c.SetStatus(Open)
-		return c.fallback()
+	if c.state.status == Closed && !c.health.Healthy() {
+		c.setStatus(Open)
+		return false
 	}
 
-	result, err := operation()
-	if err != nil {
-		c.health.AddMetric(now, health.Error)
-		return result, err
+	// reject the calls left out by the ramp up
+	if fraction := c.rampUp(now); fraction < 1 && c.rand.Float64() >= fraction {
+		if !c.shadow {
+			c.addMetric(now, health.Rejection)
+		}
+		return false
 	}
 
-	c.health.AddMetric(now, health.Success)
-	return result, nil
+	return true
+}
+
+// execute runs the operation. Without a timeout it runs on the calling goroutine and is expected to watch ctx itself.
+// With a timeout it runs on its own goroutine and is abandoned once the timeout elapses or the context is done.
+// An abandoned operation is not stopped, it keeps running until it returns and its result is discarded.
+// release is called once the operation has returned, so an abandoned operation keeps its admission slot
+func (c *CircuitBreaker) execute(ctx context.Context, config Config, operation func() (interface{}, error), release func()) (interface{}, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		defer release()
+		return operation()
+	}
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := operation()
+		release()
+		done <- outcome{result, err}
+	}()
+
+	timer := config.Clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-timer.C():
+		return nil, &TimeoutError{Timeout: timeout}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// addLatency records the latency of an operation with the Health implementation and admission controllers that support it
+func (c *CircuitBreaker) addLatency(timestamp time.Time, latency time.Duration) {
+	if recorder, ok := c.health.(LatencyRecorder); ok {
+		recorder.AddLatency(timestamp, latency)
+	}
+	for _, ac := range c.admission {
+		if recorder, ok := ac.(LatencyRecorder); ok {
+			recorder.AddLatency(timestamp, latency)
+		}
+	}
+}
+
+// addMetric records a metric in the health window and reports it to the admission controllers that learn from it
+func (c *CircuitBreaker) addMetric(timestamp time.Time, metricType health.MetricType) {
+	c.health.AddMetric(timestamp, metricType)
+	for _, ac := range c.admission {
+		if recorder, ok := ac.(MetricRecorder); ok {
+			recorder.AddMetric(timestamp, metricType)
+		}
+	}
 }
 
 // Status ...
 func (c *CircuitBreaker) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	return c.state.status
 }
 
+// Config returns the current config
+func (c *CircuitBreaker) Config() Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.config
+}
+
 // SetStatus ...
 func (c *CircuitBreaker) SetStatus(status Status) error {
 	if !status.Valid() {
 		return errors.New("invlaid status")
 	}
-	if status == c.Status() {
-		return nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setStatus(status)
+	return nil
+}
+
+// Release returns a pinned circuit to automatic operation, starting Closed. Other statuses are left as they are
+func (c *CircuitBreaker) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state.status.Pinned() {
+		c.setStatus(Closed)
 	}
+}
 
-	c.state.updated = time.Now()
+// sleepWindow returns the time to wait before probing the open circuit, grown by every failed probe. c.mu must be held
+func (c *CircuitBreaker) sleepWindow() time.Duration {
+	window := c.config.BackoffSleepWindow(c.probeFailures)
+	return window + time.Duration(float64(window)*c.jitter)
+}
+
+// setStatus changes the status and notifies the channels. c.mu must be held
+func (c *CircuitBreaker) setStatus(status Status) {
+	if status == c.state.status {
+		return
+	}
+
+	switch status {
+	case Open:
+		if c.state.status == HalfOpen {
+			c.probeFailures++
+		}
+		c.jitter = c.config.SleepWindowJitter * (2*c.rand.Float64() - 1)
+	case Closed:
+		c.probeFailures = 0
+	}
+	c.closed = time.Time{}
+
+	c.state.updated = c.config.Clock.Now()
 	c.state.status = status
 
+	if c.shadow {
+		c.emitShadow(status)
+		return
+	}
+
 	// send the new state to the channel
 	if c.stateChan != nil {
+		state := c.state
 		go func() {
-			c.stateChan <- c.state
+			c.stateChan <- state
 		}()
 	}
 
+	c.emit(StateChanged)
+}
+
+// UpdateConfig applies a new config to a live circuit breaker, resizing its health window without
+// dropping the metrics that are still inside it. The Clock cannot be changed and is ignored
+func (c *CircuitBreaker) UpdateConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the clock is kept and may not be comparable
+	current := c.config
+	current.Clock, config.Clock = nil, nil
+	if config == current {
+		return nil
+	}
+	config.Clock = c.config.Clock
+
+	c.config = config
+	if h, ok := c.health.(Reconfigurable); ok {
+		h.Reconfigure(c.healthConfig())
+	}
+
+	c.emit(ConfigChanged)
 	return nil
 }
 
-func nanoToMilli(nano int64) int64 {
-	return nano / 1e6
+// ResetHealth discards the metrics in the health window
+func (c *CircuitBreaker) ResetHealth() {
+	c.health.Reset()
 }
 
 func defaultFallback() (interface{}, error) {
 	return nil, &CircuitOpenError{}
 }
-
-// TODO  retry with default and custom backoff
-// TODO  Context Cancellation
@@ -1,8 +1,12 @@
 package circuitbreaker
 
 import (
-	"circuitbreaker/internal/health"
+	"circuitbreaker/clock/clocktest"
+	"circuitbreaker/health"
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
 	"testing"
 	"time"
@@ -21,13 +25,21 @@ func (h *HealthMock) AddMetric(timestamp time.Time, metricType health.MetricType
 	return h.err
 }
 
+func (h *HealthMock) Stats() health.Summary {
+	return health.Summary{}
+}
+
+func (h *HealthMock) Reset() {}
+
 func TestCircuitBreaker_DoWithContext(t *testing.T) {
+	now := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
 	type fields struct {
 		state     State
 		config    Config
 		stateChan chan State
 		fallback  func() (interface{}, error)
-		healthy   func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool
+		policy    health.HealthPolicy
 	}
 	type args struct {
 		ctx       context.Context
@@ -48,10 +60,11 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 				},
 				config: Config{
 					SleepWindowMillisenconds: 1000,
+					HealthMetricsWindowSize:  10,
 				},
-				healthy: func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool {
+				policy: health.PolicyFunc(func(health.Summary) bool {
 					return true
-				},
+				}),
 				stateChan: nil,
 				fallback:  nil,
 			},
@@ -69,15 +82,16 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 			fields: fields{
 				state: State{
 					status:  Open,
-					updated: time.Now(),
+					updated: now,
 				},
 				config: Config{
 					SleepWindowMillisenconds: 100000,
+					HealthMetricsWindowSize:  10,
 				},
 				stateChan: nil,
-				healthy: func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool {
+				policy: health.PolicyFunc(func(health.Summary) bool {
 					return true
-				},
+				}),
 				fallback: func() (interface{}, error) {
 					return 5, nil
 				},
@@ -96,15 +110,16 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 			fields: fields{
 				state: State{
 					status:  Open,
-					updated: time.Now().Add(-1 * time.Minute),
+					updated: now.Add(-1 * time.Minute),
 				},
 				config: Config{
 					SleepWindowMillisenconds: 1000,
+					HealthMetricsWindowSize:  10,
 				},
 				stateChan: nil,
-				healthy: func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool {
+				policy: health.PolicyFunc(func(health.Summary) bool {
 					return true
-				},
+				}),
 				fallback: func() (interface{}, error) {
 					return 5, nil
 				},
@@ -123,15 +138,16 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 			fields: fields{
 				state: State{
 					status:  Open,
-					updated: time.Now().Add(-1 * time.Minute),
+					updated: now.Add(-1 * time.Minute),
 				},
 				config: Config{
 					SleepWindowMillisenconds: 1000,
+					HealthMetricsWindowSize:  10,
 				},
 				stateChan: nil,
-				healthy: func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool {
+				policy: health.PolicyFunc(func(health.Summary) bool {
 					return true
-				},
+				}),
 				fallback: func() (interface{}, error) {
 					return 5, nil
 				},
@@ -150,15 +166,16 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 			fields: fields{
 				state: State{
 					status:  Closed,
-					updated: time.Now().Add(-1 * time.Minute),
+					updated: now.Add(-1 * time.Minute),
 				},
 				config: Config{
 					SleepWindowMillisenconds: 1000,
+					HealthMetricsWindowSize:  10,
 				},
 				stateChan: nil,
-				healthy: func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool {
+				policy: health.PolicyFunc(func(health.Summary) bool {
 					return false
-				},
+				}),
 				fallback: func() (interface{}, error) {
 					return 5, nil
 				},
@@ -177,15 +194,16 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 			fields: fields{
 				state: State{
 					status:  Open,
-					updated: time.Now().Add(-1 * time.Minute),
+					updated: now.Add(-1 * time.Minute),
 				},
 				config: Config{
 					SleepWindowMillisenconds: 1000000,
+					HealthMetricsWindowSize:  10,
 				},
 
-				healthy: func(health.Config, map[int64]map[health.MetricType]int64, []int64) bool {
+				policy: health.PolicyFunc(func(health.Summary) bool {
 					return false
-				},
+				}),
 				stateChan: nil,
 				fallback:  nil,
 			},
@@ -202,7 +220,18 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			c := New(tt.fields.config, tt.fields.stateChan, tt.fields.fallback, tt.fields.healthy)
+			config := tt.fields.config
+			config.Clock = clocktest.NewClock(now)
+
+			c, err := New("test",
+				WithConfig(config),
+				WithStateChannel(tt.fields.stateChan),
+				WithFallback(tt.fields.fallback),
+				WithHealthPolicy(tt.fields.policy),
+			)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
 			c.state = tt.fields.state
 
 			got, err := c.DoWithContext(tt.args.ctx, tt.args.operation)
@@ -216,3 +245,684 @@ func TestCircuitBreaker_DoWithContext(t *testing.T) {
 		})
 	}
 }
+
+func TestCircuitBreaker_DoWithContext_EWMA(t *testing.T) {
+	c, _ := New("test", WithSleepWindow(100*time.Second), WithHealth(
+		health.NewEWMA(health.EWMAConfig{
+			HalfLife:                 time.Minute,
+			ErrorPercentageThreshold: 0.5,
+		}),
+	))
+
+	for i := 0; i < 2; i++ {
+		c.DoWithContext(context.Background(), func() (interface{}, error) {
+			return nil, errors.New("failed")
+		})
+	}
+
+	if _, err := c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return 100, nil
+	}); err == nil {
+		t.Errorf("CircuitBreaker.DoWithContext() error = %v, wantErr %v", err, true)
+	}
+
+	if got := c.Status(); got != Open {
+		t.Errorf("CircuitBreaker.Status() = %v, want %v", got, Open)
+	}
+}
+
+func TestNew_WithHealth(t *testing.T) {
+	h := &HealthMock{healthly: false}
+	c, _ := New("test", WithSleepWindow(100*time.Second), WithHealth(h))
+
+	if c.health != h {
+		t.Errorf("New() health = %v, want %v", c.health, h)
+	}
+
+	if _, err := c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return 100, nil
+	}); err == nil {
+		t.Errorf("CircuitBreaker.DoWithContext() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestNew_WithHealthMetricsBucketSize(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test",
+		WithHealthMetricsWindow(time.Second),
+		WithHealthMetricsBucketSize(100*time.Millisecond),
+		WithClock(clock),
+	)
+
+	c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return 100, nil
+	})
+	clock.Advance(500 * time.Millisecond)
+	c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return nil, errors.New("failure")
+	})
+
+	// with 1s buckets both metrics would share a bucket and expire together
+	clock.Advance(600 * time.Millisecond)
+	if got := c.health.Stats(); got.Successes != 0 || got.Errors != 1 {
+		t.Errorf("CircuitBreaker.health.Stats() = %+v, want only the failure", got)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_SleepWindow(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithSleepWindow(time.Second), WithClock(clock))
+	c.SetStatus(Open)
+
+	operation := func() (interface{}, error) {
+		return 100, nil
+	}
+
+	clock.Advance(999 * time.Millisecond)
+	if _, err := c.DoWithContext(context.Background(), operation); err == nil {
+		t.Errorf("CircuitBreaker.DoWithContext() error = %v, wantErr %v", err, true)
+	}
+
+	clock.Advance(time.Millisecond)
+	if got, err := c.DoWithContext(context.Background(), operation); err != nil || got != 100 {
+		t.Errorf("CircuitBreaker.DoWithContext() = %v, %v, want %v", got, err, 100)
+	}
+
+	if got := c.Status(); got != Closed {
+		t.Errorf("CircuitBreaker.Status() = %v, want %v", got, Closed)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_HalfOpen(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithSleepWindow(time.Second), WithClock(clock))
+
+	failure := func() (interface{}, error) {
+		return nil, errors.New("failure")
+	}
+	c.DoWithContext(context.Background(), failure)
+	c.SetStatus(HalfOpen)
+
+	if _, err := c.DoWithContext(context.Background(), failure); err == nil || err.Error() != "failure" {
+		t.Fatalf("CircuitBreaker.DoWithContext() error = %v, want %v", err, "failure")
+	}
+	if got := c.Status(); got != Open {
+		t.Errorf("CircuitBreaker.Status() = %v after a failed probe, want %v", got, Open)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return 100, nil
+	}); err != nil {
+		t.Fatalf("CircuitBreaker.DoWithContext() error = %v", err)
+	}
+	if got := c.Status(); got != Closed {
+		t.Errorf("CircuitBreaker.Status() = %v after a successful probe, want %v", got, Closed)
+	}
+
+	// the failures from before the circuit opened do not count against it once it closes
+	if got := c.health.Stats().Requests(); got != 0 {
+		t.Errorf("CircuitBreaker.DoWithContext() requests = %v after closing, want %v", got, 0)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Shadow(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	states := make(chan State, 1)
+	events := make(chan Event)
+	c, _ := New("test",
+		WithSleepWindow(time.Second),
+		WithClock(clock),
+		WithShadowMode(),
+		WithStateChannel(states),
+		WithEventChannel(events),
+		WithFallback(func() (interface{}, error) {
+			t.Errorf("CircuitBreaker.DoWithContext() called the fallback in shadow mode")
+			return nil, nil
+		}),
+	)
+
+	failure := func() (interface{}, error) {
+		return nil, errors.New("failure")
+	}
+	calls := 0
+	success := func() (interface{}, error) {
+		calls++
+		return 100, nil
+	}
+
+	c.DoWithContext(context.Background(), failure)
+
+	// the unhealthy window would open the circuit and reject both calls
+	for i := 0; i < 2; i++ {
+		if got, err := c.DoWithContext(context.Background(), success); err != nil || got != 100 {
+			t.Errorf("CircuitBreaker.DoWithContext() = %v, %v, want %v", got, err, 100)
+		}
+	}
+	if event := <-events; event.Type != WouldOpen {
+		t.Errorf("CircuitBreaker.DoWithContext() event = %v, want %v", event.Type, WouldOpen)
+	}
+
+	clock.Advance(time.Second)
+	c.DoWithContext(context.Background(), success)
+	if event := <-events; event.Type != WouldClose {
+		t.Errorf("CircuitBreaker.DoWithContext() event = %v, want %v", event.Type, WouldClose)
+	}
+
+	if calls != 3 {
+		t.Errorf("CircuitBreaker.DoWithContext() ran %v calls, want %v", calls, 3)
+	}
+	if got := c.Snapshot(); !got.Shadow || got.WouldReject != 2 {
+		t.Errorf("CircuitBreaker.Snapshot() = %+v, want 2 calls that would have been rejected", got)
+	}
+	select {
+	case state := <-states:
+		t.Errorf("CircuitBreaker.DoWithContext() sent %+v to the state channel in shadow mode", state)
+	default:
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Timeout(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithTimeout(time.Second), WithClock(clock))
+
+	release := make(chan struct{})
+	defer close(release)
+
+	done := make(chan error)
+	go func() {
+		_, err := c.DoWithContext(context.Background(), func() (interface{}, error) {
+			<-release
+			return 100, nil
+		})
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	err := <-done
+	if _, ok := err.(*TimeoutError); !ok {
+		t.Fatalf("CircuitBreaker.DoWithContext() error = %v, want %T", err, &TimeoutError{})
+	}
+	if got := c.health.Stats().Timeouts; got != 1 {
+		t.Errorf("CircuitBreaker.DoWithContext() timeouts = %v, want %v", got, 1)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Cancelled(t *testing.T) {
+	c, _ := New("test", WithTimeout(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	_, err := c.DoWithContext(ctx, func() (interface{}, error) {
+		<-release
+		return 100, nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("CircuitBreaker.DoWithContext() error = %v, want %v", err, context.Canceled)
+	}
+	if stats := c.health.Stats(); stats.Cancellations != 1 || stats.Requests() != 1 {
+		t.Errorf("CircuitBreaker.DoWithContext() stats = %+v for a cancelled call, want a single cancellation", stats)
+	}
+}
+
+// admissionFunc is an AdmissionController calling a function
+type admissionFunc func(ctx context.Context) (func(), error)
+
+func (f admissionFunc) Acquire(ctx context.Context) (func(), error) {
+	return f(ctx)
+}
+
+func TestCircuitBreaker_DoWithContext_CancelledWhileWaiting(t *testing.T) {
+	c, _ := New("test", WithAdmissionController(admissionFunc(func(ctx context.Context) (func(), error) {
+		return nil, fmt.Errorf("waiting for a slot: %v", ctx.Err())
+	})))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a caller that gave up while waiting is neither rejected nor answered by the fallback
+	_, err := c.DoWithContext(ctx, func() (interface{}, error) {
+		return 100, nil
+	})
+	if err == nil || err.Error() != "waiting for a slot: context canceled" {
+		t.Fatalf("CircuitBreaker.DoWithContext() error = %v, want %v", err, "waiting for a slot: context canceled")
+	}
+	if got := c.health.Stats().Requests(); got != 0 {
+		t.Errorf("CircuitBreaker.DoWithContext() requests = %v, want %v", got, 0)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_NoTimeout(t *testing.T) {
+	c, _ := New("test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// without a timeout the operation runs on the calling goroutine and decides itself whether to watch ctx
+	got, err := c.DoWithContext(ctx, func() (interface{}, error) {
+		return 100, nil
+	})
+	if got != 100 || err != nil {
+		t.Fatalf("CircuitBreaker.DoWithContext() = %v, %v, want %v, %v", got, err, 100, nil)
+	}
+	if got := c.health.Stats().Successes; got != 1 {
+		t.Errorf("CircuitBreaker.DoWithContext() successes = %v, want %v", got, 1)
+	}
+}
+
+func TestCircuitBreaker_UpdateConfig(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	events := make(chan Event)
+	c, _ := New("test", WithHealthMetricsWindow(10*time.Second), WithClock(clock), WithEventChannel(events))
+
+	failure := func() (interface{}, error) {
+		return nil, errors.New("failure")
+	}
+	c.DoWithContext(context.Background(), failure)
+	clock.Advance(5 * time.Second)
+
+	config := c.Config()
+	config.HealthMetricsWindow = 20 * time.Second
+	config.SleepWindow = time.Minute
+	config.Clock = nil
+	if err := c.UpdateConfig(config); err != nil {
+		t.Fatalf("CircuitBreaker.UpdateConfig() error = %v", err)
+	}
+
+	event := <-events
+	if event.Type != ConfigChanged || event.Breaker != "test" || event.Config.SleepWindow != time.Minute {
+		t.Errorf("CircuitBreaker.UpdateConfig() event = %+v, want a ConfigChanged event with the new config", event)
+	}
+	if c.Config().Clock != clock {
+		t.Errorf("CircuitBreaker.UpdateConfig() replaced the clock")
+	}
+
+	// the failure survives the resize and is still inside the larger window
+	clock.Advance(10 * time.Second)
+	if got := c.health.Stats().Errors; got != 1 {
+		t.Errorf("CircuitBreaker.UpdateConfig() errors = %v, want %v", got, 1)
+	}
+
+	if err := c.UpdateConfig(c.Config()); err != nil {
+		t.Fatalf("CircuitBreaker.UpdateConfig() error = %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("CircuitBreaker.UpdateConfig() sent %+v for an unchanged config", event)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	config.HealthErrorPercentageThreshold = 2
+	if err := c.UpdateConfig(config); err == nil {
+		t.Errorf("CircuitBreaker.UpdateConfig() error = %v, wantErr %v", err, true)
+	}
+	if got := c.Config().HealthErrorPercentageThreshold; got != DefaultHealthErrorPercentageThreshold {
+		t.Errorf("CircuitBreaker.UpdateConfig() applied an invalid threshold %v", got)
+	}
+}
+
+// uncomparableClock panics when compared with ==
+type uncomparableClock struct {
+	*clocktest.Clock
+	tags []string
+}
+
+func TestCircuitBreaker_UpdateConfig_UncomparableClock(t *testing.T) {
+	clock := uncomparableClock{Clock: clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))}
+	events := make(chan Event, 1)
+	c, _ := New("test", WithClock(clock), WithEventChannel(events))
+
+	if err := c.UpdateConfig(c.Config()); err != nil {
+		t.Fatalf("CircuitBreaker.UpdateConfig() error = %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("CircuitBreaker.UpdateConfig() sent %+v for an unchanged config", event)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	config := c.Config()
+	config.SleepWindow = time.Minute
+	if err := c.UpdateConfig(config); err != nil {
+		t.Fatalf("CircuitBreaker.UpdateConfig() error = %v", err)
+	}
+	if event := <-events; event.Type != ConfigChanged {
+		t.Errorf("CircuitBreaker.UpdateConfig() event = %+v, want a ConfigChanged event", event)
+	}
+}
+
+func TestCircuitBreaker_UpdateConfig_BucketSize(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithClock(clock), WithHealth(health.New(health.Config{
+		WindowSize: DefaultHealthMetricsWindow,
+		BucketSize: 100 * time.Millisecond,
+		Clock:      clock,
+	}, nil)))
+	before := c.health.Stats()
+
+	config := c.Config()
+	config.SleepWindow = time.Minute
+	if err := c.UpdateConfig(config); err != nil {
+		t.Fatalf("CircuitBreaker.UpdateConfig() error = %v", err)
+	}
+
+	if got := c.health.Stats(); got.Start != before.Start {
+		t.Errorf("CircuitBreaker.UpdateConfig() window start = %v, want %v", got.Start, before.Start)
+	}
+}
+
+func TestCircuitBreaker_SetStatus_Event(t *testing.T) {
+	events := make(chan Event)
+	c, _ := New("test", WithEventChannel(events))
+
+	c.SetStatus(Open)
+
+	event := <-events
+	if event.Type != StateChanged || event.State.Status() != Open {
+		t.Errorf("CircuitBreaker.SetStatus() event = %+v, want a StateChanged event to %v", event, Open)
+	}
+}
+
+func TestCircuitBreaker_Snapshot(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithClock(clock))
+
+	c.DoWithContext(context.Background(), func() (interface{}, error) {
+		return nil, errors.New("failure")
+	})
+	clock.Advance(time.Second)
+	c.SetStatus(Open)
+
+	got := c.Snapshot()
+	if got.Name != "test" || got.State.Status() != Open || !got.State.Updated().Equal(clock.Now()) {
+		t.Errorf("CircuitBreaker.Snapshot() = %+v, want an open breaker updated at %v", got, clock.Now())
+	}
+	if got.Stats.Errors != 1 {
+		t.Errorf("CircuitBreaker.Snapshot() errors = %v, want %v", got.Stats.Errors, 1)
+	}
+
+	c.ResetHealth()
+	if got := c.Snapshot().Stats.Requests(); got != 0 {
+		t.Errorf("CircuitBreaker.ResetHealth() requests = %v, want %v", got, 0)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Pinned(t *testing.T) {
+	failure := func() (interface{}, error) {
+		return nil, errors.New("failure")
+	}
+
+	tests := []struct {
+		name         string
+		status       Status
+		wantErr      error
+		wantRequests int64
+	}{
+		{name: "forced open", status: ForcedOpen, wantErr: &CircuitOpenError{}},
+		{name: "forced closed", status: ForcedClosed, wantErr: errors.New("failure"), wantRequests: 10},
+		{name: "disabled", status: Disabled, wantErr: errors.New("failure")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+			c, _ := New("test", WithSleepWindow(time.Second), WithClock(clock))
+			c.SetStatus(tt.status)
+
+			// neither the health of the window nor the sleep window move a pinned circuit
+			for i := 0; i < 10; i++ {
+				if _, err := c.DoWithContext(context.Background(), failure); !reflect.DeepEqual(err, tt.wantErr) {
+					t.Fatalf("CircuitBreaker.DoWithContext() error = %v, want %v", err, tt.wantErr)
+				}
+				clock.Advance(500 * time.Millisecond)
+			}
+
+			if got := c.Status(); got != tt.status {
+				t.Errorf("CircuitBreaker.Status() = %v, want %v", got, tt.status)
+			}
+			if got := c.health.Stats().Requests(); got != tt.wantRequests {
+				t.Errorf("CircuitBreaker.DoWithContext() requests = %v, want %v", got, tt.wantRequests)
+			}
+
+			c.Release()
+			if got := c.Status(); got != Closed {
+				t.Errorf("CircuitBreaker.Release() status = %v, want %v", got, Closed)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_Release(t *testing.T) {
+	c, _ := New("test")
+	c.SetStatus(Open)
+
+	c.Release()
+	if got := c.Status(); got != Open {
+		t.Errorf("CircuitBreaker.Release() status = %v, want %v", got, Open)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Backoff(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithSleepWindow(time.Second), WithSleepWindowBackoff(2, 3*time.Second), WithClock(clock))
+
+	failure := func() (interface{}, error) {
+		return nil, errors.New("failure")
+	}
+	success := func() (interface{}, error) {
+		return 100, nil
+	}
+
+	c.SetStatus(Open)
+
+	// each failed probe doubles the sleep window up to the cap
+	for _, window := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if got := c.Snapshot().SleepWindow; got != window {
+			t.Fatalf("CircuitBreaker.Snapshot() sleep window = %v, want %v", got, window)
+		}
+
+		clock.Advance(window - time.Millisecond)
+		if _, err := c.DoWithContext(context.Background(), failure); !reflect.DeepEqual(err, &CircuitOpenError{}) {
+			t.Fatalf("CircuitBreaker.DoWithContext() error = %v within the sleep window, want %v", err, &CircuitOpenError{})
+		}
+
+		clock.Advance(time.Millisecond)
+		c.DoWithContext(context.Background(), failure)
+		if got := c.Status(); got != Open {
+			t.Fatalf("CircuitBreaker.Status() = %v after a failed probe, want %v", got, Open)
+		}
+	}
+
+	clock.Advance(3 * time.Second)
+	c.DoWithContext(context.Background(), success)
+	if got := c.Status(); got != Closed {
+		t.Fatalf("CircuitBreaker.Status() = %v after a successful probe, want %v", got, Closed)
+	}
+
+	c.SetStatus(Open)
+	if got := c.Snapshot().SleepWindow; got != time.Second {
+		t.Errorf("CircuitBreaker.Snapshot() sleep window = %v after closing, want %v", got, time.Second)
+	}
+}
+
+func TestCircuitBreaker_probed(t *testing.T) {
+	tests := []struct {
+		name              string
+		success           bool
+		changed           Status
+		wantStatus        Status
+		wantProbeFailures int
+		wantRequests      int64
+	}{
+		{
+			name:              "closes the circuit and discards the metrics after a success",
+			success:           true,
+			wantStatus:        Closed,
+			wantProbeFailures: 0,
+			wantRequests:      0,
+		},
+		{
+			name:              "opens the circuit and counts the failed probe after a failure",
+			success:           false,
+			wantStatus:        Open,
+			wantProbeFailures: 2,
+			wantRequests:      1,
+		},
+		{
+			name:              "ignores the result once the state has changed",
+			success:           true,
+			changed:           ForcedOpen,
+			wantStatus:        ForcedOpen,
+			wantProbeFailures: 1,
+			wantRequests:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+			c, _ := New("test", WithClock(clock))
+			c.health.AddMetric(clock.Now(), health.Error)
+
+			// a circuit whose previous probe already failed
+			c.SetStatus(HalfOpen)
+			c.SetStatus(Open)
+			c.SetStatus(HalfOpen)
+			state := c.state
+
+			if tt.changed != 0 {
+				clock.Advance(time.Second)
+				c.SetStatus(tt.changed)
+			}
+			c.probed(state, tt.success)
+
+			if got := c.Status(); got != tt.wantStatus {
+				t.Errorf("CircuitBreaker.probed() status = %v, want %v", got, tt.wantStatus)
+			}
+			if c.probeFailures != tt.wantProbeFailures {
+				t.Errorf("CircuitBreaker.probed() probe failures = %v, want %v", c.probeFailures, tt.wantProbeFailures)
+			}
+			if got := c.health.Stats().Requests(); got != tt.wantRequests {
+				t.Errorf("CircuitBreaker.probed() requests = %v, want %v", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_SleepWindowJitter(t *testing.T) {
+	c, _ := New("test", WithSleepWindow(10*time.Second), WithSleepWindowJitter(0.1))
+
+	for i := 0; i < 100; i++ {
+		c.SetStatus(Open)
+		if got := c.Snapshot().SleepWindow; got < 9*time.Second || got > 11*time.Second {
+			t.Fatalf("CircuitBreaker.Snapshot() sleep window = %v, want within 10%% of %v", got, 10*time.Second)
+		}
+		c.SetStatus(Closed)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_Jitter(t *testing.T) {
+	start := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// the time at which a breaker opened at start first runs a call
+	probe := func(seed int64) time.Duration {
+		clock := clocktest.NewClock(start)
+		c, _ := New("test",
+			WithSleepWindow(10*time.Second),
+			WithSleepWindowJitter(0.5),
+			WithRandSource(rand.NewSource(seed)),
+			WithClock(clock),
+		)
+		c.SetStatus(Open)
+
+		for {
+			ran := false
+			c.DoWithContext(context.Background(), func() (interface{}, error) {
+				ran = true
+				return nil, nil
+			})
+			if ran {
+				return clock.Now().Sub(start)
+			}
+			clock.Advance(100 * time.Millisecond)
+		}
+	}
+
+	first := probe(1)
+	if first < 5*time.Second || first > 15*time.Second {
+		t.Errorf("CircuitBreaker.DoWithContext() probed after %v, want within 50%% of %v", first, 10*time.Second)
+	}
+	if again := probe(1); again != first {
+		t.Errorf("CircuitBreaker.DoWithContext() probed after %v with the same seed, want %v", again, first)
+	}
+	if other := probe(2); other == first {
+		t.Errorf("CircuitBreaker.DoWithContext() probed after %v with another seed, want a different time", other)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_RampUp(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test",
+		WithSleepWindow(time.Second),
+		WithRampUp(10*time.Second, LinearRampUp),
+		WithRandSource(rand.NewSource(1)),
+		WithClock(clock),
+	)
+
+	calls := 0
+	success := func() (interface{}, error) {
+		calls++
+		return 100, nil
+	}
+
+	c.SetStatus(HalfOpen)
+	c.DoWithContext(context.Background(), success)
+	if got := c.Status(); got != Closed {
+		t.Fatalf("CircuitBreaker.Status() = %v after a successful probe, want %v", got, Closed)
+	}
+
+	// half way through the ramp up about half of the calls are admitted
+	clock.Advance(5 * time.Second)
+	if got := c.Snapshot().RampUp; got != 0.5 {
+		t.Errorf("CircuitBreaker.Snapshot() ramp up = %v, want %v", got, 0.5)
+	}
+
+	calls = 0
+	for i := 0; i < 1000; i++ {
+		c.DoWithContext(context.Background(), success)
+	}
+	if calls < 450 || calls > 550 {
+		t.Errorf("CircuitBreaker.DoWithContext() admitted %v of 1000 calls, want about 500", calls)
+	}
+	if got := c.health.Stats().Rejections; got != int64(1000-calls) {
+		t.Errorf("CircuitBreaker.DoWithContext() rejections = %v, want %v", got, 1000-calls)
+	}
+
+	// a failure before the ramp up ends reopens the circuit
+	for c.Status() == Closed {
+		c.DoWithContext(context.Background(), func() (interface{}, error) {
+			return nil, errors.New("failure")
+		})
+	}
+	if got := c.Status(); got != Open {
+		t.Fatalf("CircuitBreaker.Status() = %v after a failure while ramping up, want %v", got, Open)
+	}
+
+	// the ramp up ends with every call admitted
+	clock.Advance(time.Second)
+	c.DoWithContext(context.Background(), success)
+	clock.Advance(10 * time.Second)
+
+	calls = 0
+	for i := 0; i < 100; i++ {
+		c.DoWithContext(context.Background(), success)
+	}
+	if calls != 100 {
+		t.Errorf("CircuitBreaker.DoWithContext() admitted %v of 100 calls after the ramp up, want 100", calls)
+	}
+}
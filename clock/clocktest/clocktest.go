@@ -0,0 +1,189 @@
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// Clock is a clock.Clock that only moves when advanced, firing timers and tickers as it passes them
+type Clock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// NewClock ...
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now ...
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker due in that time
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to now, firing every timer and ticker due before it
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(now)
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting on the clock
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
+
+// NewTimer ...
+func (c *Clock) NewTimer(d time.Duration) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &waiter{clock: c, ch: make(chan time.Time, 1)}
+	c.schedule(w, d)
+	return w
+}
+
+// NewTicker ...
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &waiter{clock: c, ch: make(chan time.Time, 1), period: d}
+	c.schedule(w, d)
+	return ticker{w}
+}
+
+func (c *Clock) set(now time.Time) {
+	if now.Before(c.now) {
+		c.now = now
+		return
+	}
+
+	// fire waiters in order, a ticker may fire several times while catching up
+	for {
+		next := c.next()
+		if next == nil || next.when.After(now) {
+			break
+		}
+
+		c.now = next.when
+		next.fire()
+	}
+
+	c.now = now
+}
+
+// next returns the waiter that is due first
+func (c *Clock) next() *waiter {
+	var next *waiter
+	for _, w := range c.waiters {
+		if next == nil || w.when.Before(next.when) {
+			next = w
+		}
+	}
+	return next
+}
+
+func (c *Clock) schedule(w *waiter, d time.Duration) {
+	w.when = c.now.Add(d)
+	if !w.active {
+		w.active = true
+		c.waiters = append(c.waiters, w)
+		c.changed.Broadcast()
+	}
+}
+
+func (c *Clock) remove(w *waiter) bool {
+	if !w.active {
+		return false
+	}
+
+	w.active = false
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			break
+		}
+	}
+	c.changed.Broadcast()
+	return true
+}
+
+// waiter implements clock.Timer and fires repeatedly when it has a period
+type waiter struct {
+	clock  *Clock
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+	active bool
+}
+
+func (w *waiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *waiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	return w.clock.remove(w)
+}
+
+func (w *waiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	active := w.active
+	w.clock.schedule(w, d)
+	return active
+}
+
+// fire sends the current time without blocking, dropping ticks the receiver has not kept up with
+func (w *waiter) fire() {
+	select {
+	case w.ch <- w.when:
+	default:
+	}
+
+	if w.period > 0 {
+		w.when = w.when.Add(w.period)
+		return
+	}
+	w.clock.remove(w)
+}
+
+// ticker implements clock.Ticker
+type ticker struct {
+	*waiter
+}
+
+func (t ticker) Stop() {
+	t.waiter.Stop()
+}
@@ -0,0 +1,106 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestClock_Advance(t *testing.T) {
+	c := NewClock(start)
+	c.Advance(time.Minute)
+
+	if got := c.Now(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("Clock.Now() = %v, want %v", got, start.Add(time.Minute))
+	}
+
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Clock.Now() = %v, want %v", got, start)
+	}
+}
+
+func TestClock_NewTimer(t *testing.T) {
+	c := NewClock(start)
+	timer := c.NewTimer(time.Second)
+
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatalf("Timer fired before it was due")
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	select {
+	case got := <-timer.C():
+		if !got.Equal(start.Add(time.Second)) {
+			t.Errorf("Timer fired at %v, want %v", got, start.Add(time.Second))
+		}
+	default:
+		t.Fatalf("Timer did not fire when it was due")
+	}
+
+	if timer.Stop() {
+		t.Errorf("Timer.Stop() = true for a fired timer, want false")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Errorf("Timer.Reset() = true for a fired timer, want false")
+	}
+	if !timer.Stop() {
+		t.Errorf("Timer.Stop() = false for an active timer, want true")
+	}
+
+	c.Advance(time.Minute)
+	select {
+	case <-timer.C():
+		t.Errorf("Timer fired after it was stopped")
+	default:
+	}
+}
+
+func TestClock_NewTicker(t *testing.T) {
+	c := NewClock(start)
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		select {
+		case got := <-ticker.C():
+			if want := start.Add(time.Duration(i) * time.Second); !got.Equal(want) {
+				t.Errorf("Ticker fired at %v, want %v", got, want)
+			}
+		default:
+			t.Fatalf("Ticker did not fire on tick %v", i)
+		}
+	}
+
+	// ticks the receiver missed are dropped
+	c.Advance(5 * time.Second)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Errorf("Ticker buffered more than one tick")
+	default:
+	}
+}
+
+func TestClock_BlockUntil(t *testing.T) {
+	c := NewClock(start)
+	fired := make(chan time.Time)
+
+	go func() {
+		timer := c.NewTimer(time.Second)
+		fired <- <-timer.C()
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+
+	if got := <-fired; !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Timer fired at %v, want %v", got, start.Add(time.Second))
+	}
+}
@@ -0,0 +1,254 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// defaults applied by New before any Option
+const (
+	DefaultSleepWindow                    = 5 * time.Second
+	DefaultHealthMetricsWindow            = 10 * time.Second
+	DefaultHealthErrorPercentageThreshold = 0.5
+)
+
+// RampUpCurve is the shape of the fraction of calls admitted while a circuit ramps up after closing
+type RampUpCurve int64
+
+// RampUpCurve Enum
+const (
+	// LinearRampUp admits a fraction of calls growing evenly over the ramp up
+	LinearRampUp RampUpCurve = iota
+
+	// ExponentialRampUp admits a fraction of calls doubling every tenth of the ramp up, from 1/1024
+	ExponentialRampUp
+)
+
+// String ...
+func (r RampUpCurve) String() string {
+	switch r {
+	case LinearRampUp:
+		return "linear"
+	case ExponentialRampUp:
+		return "exponential"
+	}
+	return fmt.Sprintf("RampUpCurve(%d)", int64(r))
+}
+
+// fraction returns the fraction of calls admitted at progress, a ratio of the ramp up between 0 and 1
+func (r RampUpCurve) fraction(progress float64) float64 {
+	if r == ExponentialRampUp {
+		return math.Exp2(10 * (progress - 1))
+	}
+	return progress
+}
+
+// Config ...
+type Config struct {
+	// the length of time to wait before retrying when the circuit is open
+	SleepWindow time.Duration
+
+	// the length of time in milliseconds to wait before retrying when the circuit is open
+	//
+	// Deprecated: use SleepWindow, which takes precedence when set
+	SleepWindowMillisenconds int64
+
+	// the factor the sleep window grows by each time a HalfOpen probe fails, 0 or 1 keep it fixed
+	SleepWindowMultiplier float64
+
+	// the longest the sleep window may grow to, zero leaves it uncapped
+	MaxSleepWindow time.Duration
+
+	// the fraction by which each sleep window is randomly lengthened or shortened, between 0 and 1
+	SleepWindowJitter float64
+
+	// the size of the in-memory metrics window
+	HealthMetricsWindow time.Duration
+
+	// the size of the in-memory metrics window in seconds
+	//
+	// Deprecated: use HealthMetricsWindow, which takes precedence when set
+	HealthMetricsWindowSize int64
+
+	// the granularity of the metrics window, metrics expire one bucket at a time. Zero uses health.DefaultBucketSize
+	HealthMetricsBucketSize time.Duration
+
+	// the error percentage threshold determining whether a system is healthy, as a ratio between 0 and 1
+	HealthErrorPercentageThreshold float64
+
+	// the length of time over which a circuit closed by a successful probe goes from admitting no calls to all
+	// of them, zero closes it at once
+	RampUpDuration time.Duration
+
+	// the shape of the ramp up
+	RampUpCurve RampUpCurve
+
+	// the length of time an operation may run before it is abandoned and recorded as a timeout, zero disables it.
+	// An abandoned operation is not stopped, it keeps running in the background until it returns
+	Timeout time.Duration
+
+	// the source of the current time, defaults to the system clock
+	Clock clock.Clock
+}
+
+// DefaultConfig returns the Config used by New when no options are given
+func DefaultConfig() Config {
+	return Config{
+		SleepWindow:                    DefaultSleepWindow,
+		HealthMetricsWindow:            DefaultHealthMetricsWindow,
+		HealthErrorPercentageThreshold: DefaultHealthErrorPercentageThreshold,
+	}
+}
+
+// EffectiveSleepWindow returns SleepWindow, falling back to the deprecated SleepWindowMillisenconds
+func (c Config) EffectiveSleepWindow() time.Duration {
+	if c.SleepWindow != 0 {
+		return c.SleepWindow
+	}
+	return time.Duration(c.SleepWindowMillisenconds) * time.Millisecond
+}
+
+// BackoffSleepWindow returns the sleep window after a number of consecutive failed HalfOpen probes,
+// grown by SleepWindowMultiplier and capped at MaxSleepWindow. Jitter is not applied
+func (c Config) BackoffSleepWindow(failures int) time.Duration {
+	window := float64(c.EffectiveSleepWindow())
+	if c.SleepWindowMultiplier > 1 {
+		window *= math.Pow(c.SleepWindowMultiplier, float64(failures))
+	}
+
+	if c.MaxSleepWindow > 0 && window > float64(c.MaxSleepWindow) {
+		return c.MaxSleepWindow
+	}
+	if window >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(window)
+}
+
+// EffectiveHealthMetricsWindow returns HealthMetricsWindow, falling back to the deprecated HealthMetricsWindowSize
+func (c Config) EffectiveHealthMetricsWindow() time.Duration {
+	if c.HealthMetricsWindow != 0 {
+		return c.HealthMetricsWindow
+	}
+	return time.Duration(c.HealthMetricsWindowSize) * time.Second
+}
+
+// ConfigError describes an invalid Config field
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+// Validate returns a ConfigError for the first invalid field
+func (c Config) Validate() error {
+	if c.SleepWindow < 0 {
+		return &ConfigError{
+			Field:   "SleepWindow",
+			Message: fmt.Sprintf("must not be negative, got %v", c.SleepWindow),
+		}
+	}
+
+	if c.SleepWindow == 0 && c.SleepWindowMillisenconds < 0 {
+		return &ConfigError{
+			Field:   "SleepWindowMillisenconds",
+			Message: fmt.Sprintf("must not be negative, got %d", c.SleepWindowMillisenconds),
+		}
+	}
+
+	if c.SleepWindowMultiplier != 0 && c.SleepWindowMultiplier < 1 {
+		return &ConfigError{
+			Field:   "SleepWindowMultiplier",
+			Message: fmt.Sprintf("must be at least 1, got %v", c.SleepWindowMultiplier),
+		}
+	}
+
+	if c.MaxSleepWindow < 0 {
+		return &ConfigError{
+			Field:   "MaxSleepWindow",
+			Message: fmt.Sprintf("must not be negative, got %v", c.MaxSleepWindow),
+		}
+	}
+
+	if err := validateRatio("SleepWindowJitter", c.SleepWindowJitter); err != nil {
+		return err
+	}
+
+	if c.HealthMetricsWindow < 0 {
+		return &ConfigError{
+			Field:   "HealthMetricsWindow",
+			Message: fmt.Sprintf("must be positive, got %v", c.HealthMetricsWindow),
+		}
+	}
+
+	if c.HealthMetricsWindow == 0 && c.HealthMetricsWindowSize <= 0 {
+		field := "HealthMetricsWindow"
+		if c.HealthMetricsWindowSize != 0 {
+			field = "HealthMetricsWindowSize"
+		}
+		return &ConfigError{
+			Field:   field,
+			Message: fmt.Sprintf("must be positive, got %v", c.EffectiveHealthMetricsWindow()),
+		}
+	}
+
+	if c.HealthMetricsBucketSize < 0 {
+		return &ConfigError{
+			Field:   "HealthMetricsBucketSize",
+			Message: fmt.Sprintf("must not be negative, got %v", c.HealthMetricsBucketSize),
+		}
+	}
+
+	if err := validateRatio("HealthErrorPercentageThreshold", c.HealthErrorPercentageThreshold); err != nil {
+		return err
+	}
+
+	if c.RampUpDuration < 0 {
+		return &ConfigError{
+			Field:   "RampUpDuration",
+			Message: fmt.Sprintf("must not be negative, got %v", c.RampUpDuration),
+		}
+	}
+
+	if c.RampUpCurve != LinearRampUp && c.RampUpCurve != ExponentialRampUp {
+		return &ConfigError{
+			Field:   "RampUpCurve",
+			Message: fmt.Sprintf("must be LinearRampUp or ExponentialRampUp, got %v", c.RampUpCurve),
+		}
+	}
+
+	if c.Timeout < 0 {
+		return &ConfigError{
+			Field:   "Timeout",
+			Message: fmt.Sprintf("must not be negative, got %v", c.Timeout),
+		}
+	}
+
+	return nil
+}
+
+// validateRatio checks that a threshold is a ratio between 0 and 1,
+// pointing out values that look like they were given as a percentage between 0 and 100
+func validateRatio(field string, value float64) error {
+	if value >= 0 && value <= 1 {
+		return nil
+	}
+
+	if value > 1 && value <= 100 {
+		return &ConfigError{
+			Field:   field,
+			Message: fmt.Sprintf("must be a ratio between 0 and 1, got %v (use %v for %v%%)", value, value/100, value),
+		}
+	}
+
+	return &ConfigError{
+		Field:   field,
+		Message: fmt.Sprintf("must be a ratio between 0 and 1, got %v", value),
+	}
+}
@@ -0,0 +1,296 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr error
+	}{
+		{
+			name:    "accepts the default config",
+			config:  DefaultConfig(),
+			wantErr: nil,
+		},
+		{
+			name: "accepts a zero sleep window",
+			config: Config{
+				HealthMetricsWindowSize: 10,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "rejects a negative sleep window",
+			config: Config{
+				SleepWindowMillisenconds: -1,
+				HealthMetricsWindowSize:  10,
+			},
+			wantErr: &ConfigError{
+				Field:   "SleepWindowMillisenconds",
+				Message: "must not be negative, got -1",
+			},
+		},
+		{
+			name: "rejects a missing window",
+			config: Config{
+				SleepWindow: time.Second,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthMetricsWindow",
+				Message: "must be positive, got 0s",
+			},
+		},
+		{
+			name: "rejects a negative window",
+			config: Config{
+				HealthMetricsWindow: -time.Second,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthMetricsWindow",
+				Message: "must be positive, got -1s",
+			},
+		},
+		{
+			name: "rejects a negative deprecated window size",
+			config: Config{
+				HealthMetricsWindowSize: -1,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthMetricsWindowSize",
+				Message: "must be positive, got -1s",
+			},
+		},
+		{
+			name: "rejects a negative duration sleep window",
+			config: Config{
+				SleepWindow:         -time.Second,
+				HealthMetricsWindow: time.Second,
+			},
+			wantErr: &ConfigError{
+				Field:   "SleepWindow",
+				Message: "must not be negative, got -1s",
+			},
+		},
+		{
+			name: "ignores deprecated fields overridden by durations",
+			config: Config{
+				SleepWindow:              time.Second,
+				SleepWindowMillisenconds: -1,
+				HealthMetricsWindow:      time.Second,
+				HealthMetricsWindowSize:  -1,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "rejects a threshold given as a percentage",
+			config: Config{
+				HealthMetricsWindowSize:        10,
+				HealthErrorPercentageThreshold: 50,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthErrorPercentageThreshold",
+				Message: "must be a ratio between 0 and 1, got 50 (use 0.5 for 50%)",
+			},
+		},
+		{
+			name: "rejects a threshold above 100",
+			config: Config{
+				HealthMetricsWindowSize:        10,
+				HealthErrorPercentageThreshold: 500,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthErrorPercentageThreshold",
+				Message: "must be a ratio between 0 and 1, got 500",
+			},
+		},
+		{
+			name: "rejects a negative threshold",
+			config: Config{
+				HealthMetricsWindowSize:        10,
+				HealthErrorPercentageThreshold: -0.1,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthErrorPercentageThreshold",
+				Message: "must be a ratio between 0 and 1, got -0.1",
+			},
+		},
+		{
+			name: "rejects a multiplier that would shrink the sleep window",
+			config: Config{
+				HealthMetricsWindowSize: 10,
+				SleepWindowMultiplier:   0.5,
+			},
+			wantErr: &ConfigError{
+				Field:   "SleepWindowMultiplier",
+				Message: "must be at least 1, got 0.5",
+			},
+		},
+		{
+			name: "rejects a negative max sleep window",
+			config: Config{
+				HealthMetricsWindowSize: 10,
+				MaxSleepWindow:          -time.Second,
+			},
+			wantErr: &ConfigError{
+				Field:   "MaxSleepWindow",
+				Message: "must not be negative, got -1s",
+			},
+		},
+		{
+			name: "rejects a jitter above 1",
+			config: Config{
+				HealthMetricsWindowSize: 10,
+				SleepWindowJitter:       1.5,
+			},
+			wantErr: &ConfigError{
+				Field:   "SleepWindowJitter",
+				Message: "must be a ratio between 0 and 1, got 1.5 (use 0.015 for 1.5%)",
+			},
+		},
+		{
+			name: "rejects a negative bucket size",
+			config: Config{
+				HealthMetricsWindowSize: 10,
+				HealthMetricsBucketSize: -time.Millisecond,
+			},
+			wantErr: &ConfigError{
+				Field:   "HealthMetricsBucketSize",
+				Message: "must not be negative, got -1ms",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Config.Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigError_Error(t *testing.T) {
+	err := &ConfigError{Field: "HealthMetricsWindowSize", Message: "must be positive, got 0"}
+
+	if got, want := err.Error(), "invalid HealthMetricsWindowSize: must be positive, got 0"; got != want {
+		t.Errorf("ConfigError.Error() = %v, want %v", got, want)
+	}
+}
+
+func TestConfig_EffectiveWindows(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          Config
+		wantSleepWindow time.Duration
+		wantWindow      time.Duration
+	}{
+		{
+			name: "uses duration fields",
+			config: Config{
+				SleepWindow:         1500 * time.Millisecond,
+				HealthMetricsWindow: 500 * time.Millisecond,
+			},
+			wantSleepWindow: 1500 * time.Millisecond,
+			wantWindow:      500 * time.Millisecond,
+		},
+		{
+			name: "falls back to deprecated fields",
+			config: Config{
+				SleepWindowMillisenconds: 1500,
+				HealthMetricsWindowSize:  10,
+			},
+			wantSleepWindow: 1500 * time.Millisecond,
+			wantWindow:      10 * time.Second,
+		},
+		{
+			name: "prefers duration fields over deprecated fields",
+			config: Config{
+				SleepWindow:              time.Second,
+				SleepWindowMillisenconds: 1500,
+				HealthMetricsWindow:      time.Minute,
+				HealthMetricsWindowSize:  10,
+			},
+			wantSleepWindow: time.Second,
+			wantWindow:      time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.EffectiveSleepWindow(); got != tt.wantSleepWindow {
+				t.Errorf("Config.EffectiveSleepWindow() = %v, want %v", got, tt.wantSleepWindow)
+			}
+			if got := tt.config.EffectiveHealthMetricsWindow(); got != tt.wantWindow {
+				t.Errorf("Config.EffectiveHealthMetricsWindow() = %v, want %v", got, tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestConfig_BackoffSleepWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		failures int
+		want     time.Duration
+	}{
+		{
+			name:     "keeps the sleep window without a multiplier",
+			config:   Config{SleepWindow: time.Second},
+			failures: 3,
+			want:     time.Second,
+		},
+		{
+			name:     "grows the sleep window for each failure",
+			config:   Config{SleepWindow: time.Second, SleepWindowMultiplier: 2},
+			failures: 3,
+			want:     8 * time.Second,
+		},
+		{
+			name:     "caps the sleep window",
+			config:   Config{SleepWindow: time.Second, SleepWindowMultiplier: 2, MaxSleepWindow: 5 * time.Second},
+			failures: 3,
+			want:     5 * time.Second,
+		},
+		{
+			name:     "does not overflow without a cap",
+			config:   Config{SleepWindow: time.Second, SleepWindowMultiplier: 2},
+			failures: 100,
+			want:     math.MaxInt64,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.BackoffSleepWindow(tt.failures); got != tt.want {
+				t.Errorf("Config.BackoffSleepWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRampUpCurve_fraction(t *testing.T) {
+	tests := []struct {
+		curve    RampUpCurve
+		progress float64
+		want     float64
+	}{
+		{curve: LinearRampUp, progress: 0, want: 0},
+		{curve: LinearRampUp, progress: 0.25, want: 0.25},
+		{curve: LinearRampUp, progress: 1, want: 1},
+		{curve: ExponentialRampUp, progress: 0, want: 1.0 / 1024},
+		{curve: ExponentialRampUp, progress: 0.9, want: 0.5},
+		{curve: ExponentialRampUp, progress: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %v", tt.curve, tt.progress), func(t *testing.T) {
+			if got := tt.curve.fraction(tt.progress); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("RampUpCurve.fraction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
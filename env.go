@@ -0,0 +1,173 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEnvPrefix is the prefix of the variables read by WithEnvOverrides
+const DefaultEnvPrefix = "CB"
+
+// EnvError describes an environment variable that could not be applied
+type EnvError struct {
+	Variable string
+	Value    string
+	Err      error
+}
+
+func (e *EnvError) Error() string {
+	return fmt.Sprintf("invalid %s=%q: %v", e.Variable, e.Value, e.Err)
+}
+
+// envOverrides maps variable suffixes to the Config fields they set
+var envOverrides = []struct {
+	suffix string
+	apply  func(config *Config, value string) error
+}{
+	{"SLEEP_WINDOW", func(config *Config, value string) error {
+		return parseEnvDuration(value, &config.SleepWindow)
+	}},
+	{"SLEEP_WINDOW_MULTIPLIER", func(config *Config, value string) error {
+		return parseEnvFloat(value, &config.SleepWindowMultiplier)
+	}},
+	{"MAX_SLEEP_WINDOW", func(config *Config, value string) error {
+		return parseEnvDuration(value, &config.MaxSleepWindow)
+	}},
+	{"SLEEP_WINDOW_JITTER", func(config *Config, value string) error {
+		return parseEnvRatio(value, &config.SleepWindowJitter)
+	}},
+	{"WINDOW", func(config *Config, value string) error {
+		return parseEnvDuration(value, &config.HealthMetricsWindow)
+	}},
+	{"BUCKET_SIZE", func(config *Config, value string) error {
+		return parseEnvDuration(value, &config.HealthMetricsBucketSize)
+	}},
+	{"ERROR_THRESHOLD", func(config *Config, value string) error {
+		return parseEnvRatio(value, &config.HealthErrorPercentageThreshold)
+	}},
+	{"TIMEOUT", func(config *Config, value string) error {
+		return parseEnvDuration(value, &config.Timeout)
+	}},
+	{"RAMP_UP", func(config *Config, value string) error {
+		return parseEnvDuration(value, &config.RampUpDuration)
+	}},
+	{"RAMP_UP_CURVE", func(config *Config, value string) error {
+		return parseEnvRampUpCurve(value, &config.RampUpCurve)
+	}},
+}
+
+// WithEnvOverrides overrides Config fields from environment variables named
+// CB_<NAME>_<FIELD>, where NAME is the breaker name upper-cased with every character
+// other than a letter or digit replaced by an underscore. The "payments-api" breaker reads
+//
+//	CB_PAYMENTS_API_SLEEP_WINDOW             duration, e.g. 10s
+//	CB_PAYMENTS_API_SLEEP_WINDOW_MULTIPLIER  number, e.g. 2
+//	CB_PAYMENTS_API_MAX_SLEEP_WINDOW         duration, e.g. 5m
+//	CB_PAYMENTS_API_SLEEP_WINDOW_JITTER      ratio or percentage, e.g. 0.1 or 10%
+//	CB_PAYMENTS_API_WINDOW                   duration, e.g. 1m
+//	CB_PAYMENTS_API_BUCKET_SIZE              duration, e.g. 100ms
+//	CB_PAYMENTS_API_ERROR_THRESHOLD          ratio or percentage, e.g. 0.25 or 25%
+//	CB_PAYMENTS_API_TIMEOUT                  duration, e.g. 500ms
+//	CB_PAYMENTS_API_RAMP_UP                  duration, e.g. 30s
+//	CB_PAYMENTS_API_RAMP_UP_CURVE            linear or exponential
+//
+// The variables are applied after every other option, and New returns an EnvError for values that cannot be parsed
+func WithEnvOverrides() Option {
+	return WithEnvPrefix(DefaultEnvPrefix)
+}
+
+// WithEnvPrefix is WithEnvOverrides with a prefix other than CB
+func WithEnvPrefix(prefix string) Option {
+	return func(c *CircuitBreaker) {
+		c.envPrefix = prefix
+	}
+}
+
+// applyEnv overrides the config from the environment variables of the breaker
+func (c *CircuitBreaker) applyEnv() error {
+	if c.envPrefix == "" {
+		return nil
+	}
+
+	prefix := c.envPrefix + "_" + envName(c.name) + "_"
+
+	for _, override := range envOverrides {
+		variable := prefix + override.suffix
+
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			continue
+		}
+
+		if err := override.apply(&c.config, strings.TrimSpace(value)); err != nil {
+			return &EnvError{Variable: variable, Value: value, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// envName converts a breaker name to the form used in variable names
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func parseEnvDuration(value string, d *time.Duration) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("expected a duration such as 1.5s")
+	}
+
+	*d = parsed
+	return nil
+}
+
+func parseEnvFloat(value string, f *float64) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("expected a number such as 2")
+	}
+
+	*f = parsed
+	return nil
+}
+
+// parseEnvRatio accepts a ratio such as 0.25 or a percentage such as 25%
+func parseEnvRatio(value string, f *float64) error {
+	percentage := strings.HasSuffix(value, "%")
+
+	parsed, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return fmt.Errorf("expected a ratio such as 0.25 or a percentage such as 25%%")
+	}
+
+	if percentage {
+		parsed /= 100
+	}
+
+	*f = parsed
+	return nil
+}
+
+func parseEnvRampUpCurve(value string, r *RampUpCurve) error {
+	switch value {
+	case LinearRampUp.String():
+		*r = LinearRampUp
+	case ExponentialRampUp.String():
+		*r = ExponentialRampUp
+	default:
+		return fmt.Errorf("expected %s or %s", LinearRampUp, ExponentialRampUp)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package circuitbreaker
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNew_WithEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name       string
+		breaker    string
+		opts       []Option
+		env        map[string]string
+		wantConfig Config
+		wantErr    string
+	}{
+		{
+			name:    "overrides config fields",
+			breaker: "payments-api",
+			opts: []Option{
+				WithSleepWindow(time.Second),
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_API_SLEEP_WINDOW":            "10s",
+				"CB_PAYMENTS_API_SLEEP_WINDOW_MULTIPLIER": "2",
+				"CB_PAYMENTS_API_MAX_SLEEP_WINDOW":        "5m",
+				"CB_PAYMENTS_API_SLEEP_WINDOW_JITTER":     "10%",
+				"CB_PAYMENTS_API_WINDOW":                  "1m",
+				"CB_PAYMENTS_API_BUCKET_SIZE":             "100ms",
+				"CB_PAYMENTS_API_ERROR_THRESHOLD":         "0.25",
+				"CB_PAYMENTS_API_TIMEOUT":                 "500ms",
+				"CB_PAYMENTS_API_RAMP_UP":                 "30s",
+				"CB_PAYMENTS_API_RAMP_UP_CURVE":           "exponential",
+			},
+			wantConfig: Config{
+				SleepWindow:                    10 * time.Second,
+				SleepWindowMultiplier:          2,
+				MaxSleepWindow:                 5 * time.Minute,
+				SleepWindowJitter:              0.1,
+				HealthMetricsWindow:            time.Minute,
+				HealthMetricsBucketSize:        100 * time.Millisecond,
+				HealthErrorPercentageThreshold: 0.25,
+				Timeout:                        500 * time.Millisecond,
+				RampUpDuration:                 30 * time.Second,
+				RampUpCurve:                    ExponentialRampUp,
+			},
+		},
+		{
+			name:    "applies after later options",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+				WithSleepWindow(time.Second),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_SLEEP_WINDOW": "10s",
+			},
+			wantConfig: Config{
+				SleepWindow:                    10 * time.Second,
+				HealthMetricsWindow:            DefaultHealthMetricsWindow,
+				HealthErrorPercentageThreshold: DefaultHealthErrorPercentageThreshold,
+			},
+		},
+		{
+			name:    "parses percentages",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_ERROR_THRESHOLD": "25%",
+			},
+			wantConfig: Config{
+				SleepWindow:                    DefaultSleepWindow,
+				HealthMetricsWindow:            DefaultHealthMetricsWindow,
+				HealthErrorPercentageThreshold: 0.25,
+			},
+		},
+		{
+			name:    "uses a custom prefix",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvPrefix("BREAKER"),
+			},
+			env: map[string]string{
+				"BREAKER_PAYMENTS_SLEEP_WINDOW": "10s",
+				"CB_PAYMENTS_WINDOW":            "1m",
+			},
+			wantConfig: Config{
+				SleepWindow:                    10 * time.Second,
+				HealthMetricsWindow:            DefaultHealthMetricsWindow,
+				HealthErrorPercentageThreshold: DefaultHealthErrorPercentageThreshold,
+			},
+		},
+		{
+			name:    "ignores variables without the option",
+			breaker: "payments",
+			env: map[string]string{
+				"CB_PAYMENTS_SLEEP_WINDOW": "10s",
+			},
+			wantConfig: DefaultConfig(),
+		},
+		{
+			name:    "reports unparseable durations",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_SLEEP_WINDOW": "10",
+			},
+			wantErr: `invalid CB_PAYMENTS_SLEEP_WINDOW="10": expected a duration such as 1.5s`,
+		},
+		{
+			name:    "reports unparseable thresholds",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_ERROR_THRESHOLD": "half",
+			},
+			wantErr: `invalid CB_PAYMENTS_ERROR_THRESHOLD="half": expected a ratio such as 0.25 or a percentage such as 25%`,
+		},
+		{
+			name:    "reports unparseable multipliers",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_SLEEP_WINDOW_MULTIPLIER": "2x",
+			},
+			wantErr: `invalid CB_PAYMENTS_SLEEP_WINDOW_MULTIPLIER="2x": expected a number such as 2`,
+		},
+		{
+			name:    "reports unknown ramp up curves",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_RAMP_UP_CURVE": "quadratic",
+			},
+			wantErr: `invalid CB_PAYMENTS_RAMP_UP_CURVE="quadratic": expected linear or exponential`,
+		},
+		{
+			name:    "validates overridden values",
+			breaker: "payments",
+			opts: []Option{
+				WithEnvOverrides(),
+			},
+			env: map[string]string{
+				"CB_PAYMENTS_ERROR_THRESHOLD": "-5%",
+			},
+			wantErr: "invalid HealthErrorPercentageThreshold: must be a ratio between 0 and 1, got -0.05",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for variable, value := range tt.env {
+				os.Setenv(variable, value)
+				defer os.Unsetenv(variable)
+			}
+
+			c, err := New(tt.breaker, tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("New() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			config := c.config
+			config.Clock = nil
+			if config != tt.wantConfig {
+				t.Errorf("New() config = %+v, want %+v", config, tt.wantConfig)
+			}
+		})
+	}
+}
+
+func Test_envName(t *testing.T) {
+	for name, want := range map[string]string{
+		"payments":        "PAYMENTS",
+		"payments-api":    "PAYMENTS_API",
+		"db.replica/eu-1": "DB_REPLICA_EU_1",
+		"Search_V2":       "SEARCH_V2",
+		"caché":           "CACH_",
+	} {
+		if got := envName(name); got != want {
+			t.Errorf("envName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
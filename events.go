@@ -0,0 +1,62 @@
+package circuitbreaker
+
+import "time"
+
+// EventType ...
+type EventType int64
+
+// EventType Enum
+const (
+	StateChanged EventType = iota + 1
+	ConfigChanged
+
+	// sent instead of StateChanged by a circuit in shadow mode
+	WouldOpen
+	WouldClose
+)
+
+// Event describes a change to a circuit breaker
+type Event struct {
+	Type    EventType
+	Breaker string
+	State   State
+	Config  Config
+	Time    time.Time
+}
+
+// WithEventChannel sets a channel that receives every Event
+func WithEventChannel(ch chan Event) Option {
+	return func(c *CircuitBreaker) {
+		c.eventChan = ch
+	}
+}
+
+// emit sends an event of eventType to the event channel without blocking the caller. c.mu must be held
+func (c *CircuitBreaker) emit(eventType EventType) {
+	if c.eventChan == nil {
+		return
+	}
+
+	event := Event{
+		Type:    eventType,
+		Breaker: c.name,
+		State:   c.state,
+		Config:  c.config,
+		Time:    c.config.Clock.Now(),
+	}
+
+	go func() {
+		c.eventChan <- event
+	}()
+}
+
+// emitShadow reports the transitions of a shadow circuit that would have changed how calls are handled.
+// c.mu must be held
+func (c *CircuitBreaker) emitShadow(status Status) {
+	switch status {
+	case Open, ForcedOpen:
+		c.emit(WouldOpen)
+	case Closed, ForcedClosed:
+		c.emit(WouldClose)
+	}
+}
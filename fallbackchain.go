@@ -0,0 +1,92 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FallbackStep is a source of a FallbackChain, such as a primary database, a replica or a cache
+type FallbackStep struct {
+	Name      string
+	Operation Operation
+
+	// the circuit breaker guarding the step, nil calls the operation directly. Its fallback is not called
+	Breaker *CircuitBreaker
+}
+
+// StepError is the failure of a single FallbackStep
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+// ChainError lists the failure of every step when no step of a FallbackChain could serve a response
+type ChainError struct {
+	Errors []*StepError
+}
+
+func (e *ChainError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "every fallback step failed: " + strings.Join(messages, "; ")
+}
+
+// ChainResult is a response served by a FallbackChain
+type ChainResult struct {
+	Value interface{}
+
+	// the name of the step that served the response
+	Step string
+}
+
+// FallbackChain tries its steps in order until one of them serves a response.
+// Steps whose circuit is open are skipped without calling their operation
+type FallbackChain struct {
+	steps []FallbackStep
+}
+
+// NewFallbackChain ...
+func NewFallbackChain(steps ...FallbackStep) *FallbackChain {
+	return &FallbackChain{
+		steps: steps,
+	}
+}
+
+// Execute returns the response of the first step to succeed, or a ChainError listing the failure of every step.
+// The chain stops with the context error once ctx is done
+func (f *FallbackChain) Execute(ctx context.Context) (ChainResult, error) {
+	var errs []*StepError
+
+	for i, step := range f.steps {
+		if err := ctx.Err(); err != nil {
+			return ChainResult{}, err
+		}
+
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		var value interface{}
+		var err error
+		if step.Breaker != nil {
+			value, err = step.Breaker.executeOrReject(ctx, step.Operation)
+		} else {
+			value, err = step.Operation(ctx)
+		}
+
+		if err == nil {
+			return ChainResult{Value: value, Step: name}, nil
+		}
+		errs = append(errs, &StepError{Step: name, Err: err})
+	}
+
+	return ChainResult{}, &ChainError{Errors: errs}
+}
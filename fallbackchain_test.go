@@ -0,0 +1,83 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFallbackChain_Execute(t *testing.T) {
+	primary, _ := New("primary", WithFallback(func() (interface{}, error) {
+		return "primary fallback", nil
+	}))
+	replica, _ := New("replica")
+
+	calls := map[string]int{}
+	step := func(name string, breaker *CircuitBreaker, err error) FallbackStep {
+		return FallbackStep{
+			Name:    name,
+			Breaker: breaker,
+			Operation: func(ctx context.Context) (interface{}, error) {
+				calls[name]++
+				if err != nil {
+					return nil, err
+				}
+				return name, nil
+			},
+		}
+	}
+
+	chain := NewFallbackChain(
+		step("primary", primary, nil),
+		step("replica", replica, errors.New("replica lag")),
+		step("cache", nil, nil),
+	)
+
+	got, err := chain.Execute(context.Background())
+	if err != nil || !reflect.DeepEqual(got, ChainResult{Value: "primary", Step: "primary"}) {
+		t.Errorf("FallbackChain.Execute() = %+v, %v, want the primary", got, err)
+	}
+
+	// an open step is skipped without calling it or the fallback of its breaker
+	primary.SetStatus(ForcedOpen)
+	got, err = chain.Execute(context.Background())
+	if err != nil || !reflect.DeepEqual(got, ChainResult{Value: "cache", Step: "cache"}) {
+		t.Errorf("FallbackChain.Execute() = %+v, %v, want the cache", got, err)
+	}
+	if calls["primary"] != 1 || calls["replica"] != 1 || calls["cache"] != 1 {
+		t.Errorf("FallbackChain.Execute() calls = %v, want 1 each", calls)
+	}
+	if got := replica.health.Stats().Errors; got != 1 {
+		t.Errorf("FallbackChain.Execute() replica errors = %v, want %v", got, 1)
+	}
+}
+
+func TestFallbackChain_Execute_ChainError(t *testing.T) {
+	chain := NewFallbackChain(
+		FallbackStep{Name: "primary", Operation: func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("connection refused")
+		}},
+		FallbackStep{Operation: func(ctx context.Context) (interface{}, error) {
+			return nil, &TimeoutError{Timeout: 0}
+		}},
+	)
+
+	_, err := chain.Execute(context.Background())
+	chainErr, ok := err.(*ChainError)
+	if !ok {
+		t.Fatalf("FallbackChain.Execute() error = %v, want %T", err, &ChainError{})
+	}
+	if len(chainErr.Errors) != 2 || chainErr.Errors[1].Step != "step 2" {
+		t.Errorf("FallbackChain.Execute() errors = %v, want one for each step", chainErr.Errors)
+	}
+	if want := "every fallback step failed: primary: connection refused; step 2: operation timed out after 0s"; err.Error() != want {
+		t.Errorf("ChainError.Error() = %v, want %v", err, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := chain.Execute(ctx); err != context.Canceled {
+		t.Errorf("FallbackChain.Execute() error = %v, want %v", err, context.Canceled)
+	}
+}
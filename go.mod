@@ -6,4 +6,5 @@ require (
 	github.com/bombsimon/wsl v1.2.5 // indirect
 	github.com/golangci/golangci-lint v1.23.6 // indirect
 	google.golang.org/appengine v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
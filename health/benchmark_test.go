@@ -0,0 +1,159 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+// legacyHealth is the previous map and sorted slice implementation, kept to benchmark against
+type legacyHealth struct {
+	metrics map[int64]map[MetricType]int64
+	keys    []int64
+	config  Config
+}
+
+func (c *legacyHealth) Healthy(now time.Time) bool {
+	index := legacyBinarySearchIndex(now.Unix()-int64(c.config.WindowSize/time.Second), c.keys)
+	for _, key := range c.keys[:index] {
+		delete(c.metrics, key)
+	}
+	c.keys = c.keys[index:]
+
+	var successful, failed float64
+	for _, key := range c.keys {
+		successful += float64(c.metrics[key][Success])
+		successful += float64(c.metrics[key][Rejection])
+		failed += float64(c.metrics[key][Error])
+		failed += float64(c.metrics[key][Timeout])
+	}
+
+	return (failed / (successful + failed)) < c.config.ErrorPercentageThreshold
+}
+
+func (c *legacyHealth) AddMetric(timestamp time.Time, metricType MetricType) error {
+	key := timestamp.Unix()
+
+	if _, ok := c.metrics[key]; !ok {
+		c.metrics[key] = map[MetricType]int64{}
+		index := legacyBinarySearchIndex(key, c.keys)
+		c.keys = append(c.keys, 0)
+		copy(c.keys[index+1:], c.keys[index:])
+		c.keys[index] = key
+	}
+
+	c.metrics[key][metricType]++
+
+	return nil
+}
+
+func legacyBinarySearchIndex(key int64, keys []int64) int {
+	low := 0
+	high := len(keys) - 1
+
+	for low <= high {
+		index := (low + high) / 2
+
+		if keys[index] == key {
+			return index + 1
+		}
+
+		if keys[index] <= key {
+			low = index + 1
+			continue
+		}
+
+		high = index - 1
+	}
+
+	return low
+}
+
+var benchmarkConfig = Config{
+	WindowSize:               time.Minute,
+	ErrorPercentageThreshold: 0.5,
+}
+
+// benchmarkTimestamp spreads metrics over new seconds so that bucket rollover is exercised
+func benchmarkTimestamp(i int) time.Time {
+	return time.Unix(946728000+int64(i/100), 0)
+}
+
+func BenchmarkHealth_AddMetric(b *testing.B) {
+	c := New(benchmarkConfig, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.AddMetric(benchmarkTimestamp(i), Success)
+	}
+}
+
+func BenchmarkLegacyHealth_AddMetric(b *testing.B) {
+	c := &legacyHealth{
+		metrics: map[int64]map[MetricType]int64{},
+		config:  benchmarkConfig,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.AddMetric(benchmarkTimestamp(i), Success)
+		// trim the window as the circuit breaker would between calls
+		if i%100 == 0 {
+			c.Healthy(benchmarkTimestamp(i))
+		}
+	}
+}
+
+func BenchmarkHealth_AddMetricParallel(b *testing.B) {
+	c := New(benchmarkConfig, nil)
+	timestamp := time.Unix(946728000, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.AddMetric(timestamp, Success)
+		}
+	})
+}
+
+func BenchmarkHealth_Healthy(b *testing.B) {
+	config := benchmarkConfig
+	config.Clock = clocktest.NewClock(benchmarkTimestamp(6000))
+
+	c := New(config, nil)
+	for i := 0; i < 6000; i++ {
+		c.AddMetric(benchmarkTimestamp(i), Success)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.Healthy()
+	}
+}
+
+func BenchmarkLegacyHealth_Healthy(b *testing.B) {
+	c := &legacyHealth{
+		metrics: map[int64]map[MetricType]int64{},
+		config:  benchmarkConfig,
+	}
+	for i := 0; i < 6000; i++ {
+		c.AddMetric(benchmarkTimestamp(i), Success)
+	}
+	now := benchmarkTimestamp(6000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.Healthy(now)
+	}
+}
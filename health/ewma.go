@@ -0,0 +1,225 @@
+package health
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// DefaultHalfLife is the half-life used when EWMAConfig.HalfLife is not set
+const DefaultHalfLife = 10 * time.Second
+
+// EWMAConfig ...
+type EWMAConfig struct {
+	// the time it takes for the weight of a metric to halve
+	HalfLife time.Duration
+
+	// the decayed failure rate threshold determining whether a system is healthy
+	ErrorPercentageThreshold float64
+
+	// the decayed mean latency at or above which a system is unhealthy, zero disables the check
+	LatencyThreshold time.Duration
+
+	// the source of the current time, defaults to the system clock
+	Clock clock.Clock
+}
+
+// EWMA tracks exponentially weighted moving averages of the failure rate and latency.
+// Metrics lose half of their weight every HalfLife, so there are no window edges
+type EWMA struct {
+	mu      sync.Mutex
+	config  EWMAConfig
+	updated time.Time
+
+	// decayed weights of each MetricType
+	counts [numMetricTypes]float64
+
+	// decayed sum and weight of latency samples in nanoseconds
+	latency   float64
+	latencies float64
+}
+
+// NewEWMA ...
+func NewEWMA(config EWMAConfig) *EWMA {
+	if config.HalfLife <= 0 {
+		config.HalfLife = DefaultHalfLife
+	}
+
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+
+	return &EWMA{
+		config: config,
+	}
+}
+
+// Healthy ...
+func (e *EWMA) Healthy() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.decay(e.config.Clock.Now())
+
+	if completed := e.completed(); completed > 0 && e.failures()/completed >= e.config.ErrorPercentageThreshold {
+		return false
+	}
+
+	if e.config.LatencyThreshold > 0 && e.latencies > 0 &&
+		time.Duration(e.latency/e.latencies) >= e.config.LatencyThreshold {
+		return false
+	}
+
+	return true
+}
+
+// AddMetric ...
+func (e *EWMA) AddMetric(timestamp time.Time, metricType MetricType) error {
+	if !metricType.Valid() {
+		return errors.New("invalid MetricType")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.counts[metricType-1] += e.weight(timestamp)
+
+	return nil
+}
+
+// AddLatency ...
+func (e *EWMA) AddLatency(timestamp time.Time, latency time.Duration) error {
+	if latency < 0 {
+		return errors.New("invalid latency")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	weight := e.weight(timestamp)
+
+	e.latency += weight * float64(latency)
+	e.latencies += weight
+
+	return nil
+}
+
+// FailureRate returns the decayed ratio of failed requests
+func (e *EWMA) FailureRate() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.decay(e.config.Clock.Now())
+
+	completed := e.completed()
+	if completed <= 0 {
+		return 0
+	}
+	return e.failures() / completed
+}
+
+// MeanLatency returns the decayed mean latency
+func (e *EWMA) MeanLatency() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.decay(e.config.Clock.Now())
+
+	if e.latencies == 0 {
+		return 0
+	}
+	return time.Duration(e.latency / e.latencies)
+}
+
+// Stats returns the decayed metrics rounded to whole counts.
+// The window is nominal, metrics recorded within the last half-life keep at least half of their weight
+func (e *EWMA) Stats() Summary {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.config.Clock.Now()
+	e.decay(now)
+
+	return Summary{
+		Start:         now.Add(-e.config.HalfLife),
+		End:           now,
+		Successes:     int64(math.Round(e.counts[Success-1])),
+		Errors:        int64(math.Round(e.counts[Error-1])),
+		Timeouts:      int64(math.Round(e.counts[Timeout-1])),
+		Rejections:    int64(math.Round(e.counts[Rejection-1])),
+		Cancellations: int64(math.Round(e.counts[Cancellation-1])),
+		Latencies:     int64(math.Round(e.latencies)),
+		LatencyTotal:  time.Duration(e.latency),
+	}
+}
+
+// Reconfigure applies the error percentage threshold of a window Config, an EWMA has no window to resize
+func (e *EWMA) Reconfigure(config Config) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.ErrorPercentageThreshold = config.ErrorPercentageThreshold
+}
+
+// Reset discards every metric
+func (e *EWMA) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.counts = [numMetricTypes]float64{}
+	e.latency = 0
+	e.latencies = 0
+}
+
+func (e *EWMA) requests() float64 {
+	var requests float64
+	for _, count := range e.counts {
+		requests += count
+	}
+	return requests
+}
+
+// completed returns the requests without the cancelled ones, whose outcome is unknown
+func (e *EWMA) completed() float64 {
+	return e.requests() - e.counts[Cancellation-1]
+}
+
+func (e *EWMA) failures() float64 {
+	return e.counts[Error-1] + e.counts[Timeout-1]
+}
+
+// weight decays the averages up to timestamp and returns the weight of a metric recorded at timestamp.
+// Metrics older than the last update are added with their already decayed weight
+func (e *EWMA) weight(timestamp time.Time) float64 {
+	if timestamp.Before(e.updated) {
+		return e.factor(e.updated.Sub(timestamp))
+	}
+
+	e.decay(timestamp)
+	return 1
+}
+
+// decay applies the decay for the time elapsed since the last update
+func (e *EWMA) decay(now time.Time) {
+	if !now.After(e.updated) {
+		return
+	}
+
+	if !e.updated.IsZero() {
+		factor := e.factor(now.Sub(e.updated))
+		for i := range e.counts {
+			e.counts[i] *= factor
+		}
+		e.latency *= factor
+		e.latencies *= factor
+	}
+
+	e.updated = now
+}
+
+func (e *EWMA) factor(elapsed time.Duration) float64 {
+	return math.Exp2(-float64(elapsed) / float64(e.config.HalfLife))
+}
@@ -0,0 +1,215 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+type latency struct {
+	timestamp time.Time
+	latency   time.Duration
+}
+
+func TestEWMA_Healthy(t *testing.T) {
+	start := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type fields struct {
+		metrics   []metric
+		latencies []latency
+		config    EWMAConfig
+		now       time.Time
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   bool
+	}{
+		{
+			name: "is healthy without metrics",
+			fields: fields{
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: start,
+			},
+			want: true,
+		},
+		{
+			name: "detects a healthy system",
+			fields: fields{
+				metrics: concat(
+					repeat(start, Success, 9),
+					repeat(start, Error, 1),
+				),
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: start,
+			},
+			want: true,
+		},
+		{
+			name: "detects an unhealthy system",
+			fields: fields{
+				metrics: concat(
+					repeat(start, Success, 4),
+					repeat(start, Error, 6),
+				),
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: start,
+			},
+			want: false,
+		},
+		{
+			name: "ignores cancelled requests",
+			fields: fields{
+				metrics: concat(
+					repeat(start, Success, 4),
+					repeat(start, Error, 6),
+					repeat(start, Cancellation, 10),
+				),
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: start,
+			},
+			want: false,
+		},
+		{
+			name: "decays old failures",
+			fields: fields{
+				metrics: concat(
+					repeat(start, Error, 10),
+					repeat(start.Add(4*time.Second), Success, 2),
+				),
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: start.Add(4 * time.Second),
+			},
+			want: true,
+		},
+		{
+			name: "weights late metrics by their age",
+			fields: fields{
+				metrics: concat(
+					repeat(start.Add(4*time.Second), Success, 2),
+					repeat(start, Error, 10),
+				),
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: start.Add(4 * time.Second),
+			},
+			want: true,
+		},
+		{
+			name: "detects a slow system",
+			fields: fields{
+				metrics: repeat(start, Success, 2),
+				latencies: []latency{
+					{start, 100 * time.Millisecond},
+					{start, 300 * time.Millisecond},
+				},
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+					LatencyThreshold:         200 * time.Millisecond,
+				},
+				now: start,
+			},
+			want: false,
+		},
+		{
+			name: "decays old latency",
+			fields: fields{
+				metrics: repeat(start, Success, 2),
+				latencies: []latency{
+					{start, time.Second},
+					{start.Add(4 * time.Second), 100 * time.Millisecond},
+				},
+				config: EWMAConfig{
+					HalfLife:                 time.Second,
+					ErrorPercentageThreshold: 0.5,
+					LatencyThreshold:         200 * time.Millisecond,
+				},
+				now: start.Add(4 * time.Second),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			config := tt.fields.config
+			config.Clock = clocktest.NewClock(tt.fields.now)
+
+			e := NewEWMA(config)
+			for _, m := range tt.fields.metrics {
+				e.AddMetric(m.timestamp, m.metric)
+			}
+			for _, l := range tt.fields.latencies {
+				e.AddLatency(l.timestamp, l.latency)
+			}
+
+			if got := e.Healthy(); got != tt.want {
+				t.Errorf("EWMA.Healthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEWMA_FailureRate(t *testing.T) {
+	start := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e := NewEWMA(EWMAConfig{HalfLife: time.Second, Clock: clocktest.NewClock(start.Add(time.Second))})
+	e.AddMetric(start, Error)
+	e.AddMetric(start.Add(time.Second), Success)
+	e.AddMetric(start.Add(time.Second), Cancellation)
+
+	// the error has lost half of its weight by the time the success is recorded, the cancellation does not count
+	if got, want := e.FailureRate(), 0.5/1.5; got != want {
+		t.Errorf("EWMA.FailureRate() = %v, want %v", got, want)
+	}
+
+	if err := e.AddMetric(start, -1); err == nil {
+		t.Errorf("EWMA.AddMetric() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestEWMA_Stats(t *testing.T) {
+	start := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e := NewEWMA(EWMAConfig{HalfLife: time.Second, Clock: clocktest.NewClock(start.Add(time.Second))})
+	for _, m := range concat(repeat(start, Error, 4), repeat(start.Add(time.Second), Success, 3)) {
+		e.AddMetric(m.timestamp, m.metric)
+	}
+	e.AddLatency(start.Add(time.Second), time.Second)
+
+	want := Summary{
+		Start:        start,
+		End:          start.Add(time.Second),
+		Successes:    3,
+		Errors:       2,
+		Latencies:    1,
+		LatencyTotal: time.Second,
+	}
+	if got := e.Stats(); got != want {
+		t.Errorf("EWMA.Stats() = %+v, want %+v", got, want)
+	}
+
+	e.Reset()
+	if got := e.Stats(); got.Requests() != 0 || got.Latencies != 0 {
+		t.Errorf("EWMA.Stats() after Reset() = %+v, want no metrics", got)
+	}
+}
@@ -0,0 +1,344 @@
+package health
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// MetricType ...
+type MetricType int64
+
+// Valid determines whether a MetricType is valid
+func (m *MetricType) Valid() bool {
+	return *m >= 1 && *m <= 5
+}
+
+// MetricType Enum
+const (
+	Success MetricType = iota + 1
+	Error
+	Timeout
+	Rejection
+
+	// Cancellation is a call abandoned by its caller before it completed, neither a success nor a failure
+	Cancellation
+)
+
+// numMetricTypes is the number of valid MetricType values
+const numMetricTypes = 5
+
+// DefaultBucketSize is the bucket granularity used when Config.BucketSize is not set
+const DefaultBucketSize = time.Second
+
+// Config ...
+type Config struct {
+	// the length of the metrics window
+	WindowSize time.Duration
+
+	// the granularity of the window, metrics expire one bucket at a time
+	BucketSize time.Duration
+
+	ErrorPercentageThreshold float64
+
+	// the source of the current time, defaults to the system clock
+	Clock clock.Clock
+}
+
+// bucket holds the metric counters for a single interval of the window
+type bucket struct {
+	key    int64
+	counts [numMetricTypes]int64
+
+	// number, total and maximum of the latencies in nanoseconds
+	latencies    int64
+	latencyTotal int64
+	latencyMax   int64
+}
+
+// Health tracks metrics in a fixed-size ring of buckets
+type Health struct {
+	// mu holds writers off while Reconfigure copies the ring, readers load the ring atomically
+	mu     sync.RWMutex
+	ring   atomic.Value
+	policy HealthPolicy
+}
+
+// ring is the bucket ring for a single Config, replaced as a whole by Reconfigure
+type ring struct {
+	// mu guards bucket rollover, counters are updated atomically
+	mu      sync.Mutex
+	buckets []bucket
+	width   int64
+	config  Config
+	policy  HealthPolicy
+}
+
+// New ...
+func New(config Config, policy HealthPolicy) *Health {
+	c := &Health{
+		policy: policy,
+	}
+	c.ring.Store(c.newRing(config))
+
+	return c
+}
+
+// newRing ...
+func (c *Health) newRing(config Config) *ring {
+
+	policy := c.policy
+	if policy == nil {
+		policy = ErrorPercentage(config.ErrorPercentageThreshold)
+	}
+
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+
+	width := config.BucketSize
+	if width <= 0 {
+		width = DefaultBucketSize
+	}
+	if config.WindowSize > 0 && config.WindowSize < width {
+		width = config.WindowSize
+	}
+
+	// the window is rounded up to a whole number of buckets
+	size := int64((config.WindowSize + width - 1) / width)
+	if size < 1 {
+		size = 1
+	}
+
+	return &ring{
+		buckets: make([]bucket, size),
+		width:   int64(width),
+		config:  config,
+		policy:  policy,
+	}
+}
+
+func (c *Health) load() *ring {
+	return c.ring.Load().(*ring)
+}
+
+// Healthy ...
+func (c *Health) Healthy() bool {
+	r := c.load()
+	return r.policy.Healthy(r.summary(r.config.Clock.Now()))
+}
+
+// Stats returns a summary of the metrics in the window
+func (c *Health) Stats() Summary {
+	r := c.load()
+	return r.summary(r.config.Clock.Now())
+}
+
+// Reset discards every metric in the window
+func (c *Health) Reset() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	r := c.load()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.buckets {
+		r.buckets[i].reset(0)
+	}
+}
+
+// Reconfigure applies a new Config, moving the metrics that are still inside the new window
+// into buckets of the new size. The Clock and BucketSize are kept when config does not set them
+func (c *Health) Reconfigure(config Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	old := c.load()
+	if config.Clock == nil {
+		config.Clock = old.config.Clock
+	}
+	if config.BucketSize <= 0 {
+		config.BucketSize = old.config.BucketSize
+	}
+
+	r := c.newRing(config)
+	now := config.Clock.Now()
+
+	for i := range old.buckets {
+		b := &old.buckets[i]
+		key := atomic.LoadInt64(&b.key)
+		if old.expired(key, now) {
+			continue
+		}
+
+		target := r.bucket(r.key(time.Unix(0, key*old.width)))
+		if target == nil || r.expired(atomic.LoadInt64(&target.key), now) {
+			continue
+		}
+		target.add(b)
+	}
+
+	c.ring.Store(r)
+}
+
+// AddMetric ...
+func (c *Health) AddMetric(timestamp time.Time, metricType MetricType) error {
+	if !metricType.Valid() {
+		return errors.New("invalid MetricType")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	r := c.load()
+
+	// metrics older than the ring are dropped
+	if b := r.bucket(r.key(timestamp)); b != nil {
+		atomic.AddInt64(&b.counts[metricType-1], 1)
+	}
+
+	return nil
+}
+
+// AddLatency ...
+func (c *Health) AddLatency(timestamp time.Time, latency time.Duration) error {
+	if latency < 0 {
+		return errors.New("invalid latency")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	r := c.load()
+
+	b := r.bucket(r.key(timestamp))
+	if b == nil {
+		return nil
+	}
+
+	atomic.AddInt64(&b.latencies, 1)
+	atomic.AddInt64(&b.latencyTotal, int64(latency))
+	b.maxLatency(int64(latency))
+
+	return nil
+}
+
+// bucket returns the bucket for key, resetting it when it still holds an older key.
+// nil is returned when the slot has already been reused by a newer key
+func (r *ring) bucket(key int64) *bucket {
+	b := &r.buckets[r.index(key)]
+
+	current := atomic.LoadInt64(&b.key)
+	if current == key {
+		return b
+	}
+	if current > key {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current = atomic.LoadInt64(&b.key)
+	if current > key {
+		return nil
+	}
+	if current < key {
+		b.reset(key)
+	}
+
+	return b
+}
+
+// reset zeroes the counters of a bucket and assigns it to key
+func (b *bucket) reset(key int64) {
+	for i := range b.counts {
+		atomic.StoreInt64(&b.counts[i], 0)
+	}
+	atomic.StoreInt64(&b.latencies, 0)
+	atomic.StoreInt64(&b.latencyTotal, 0)
+	atomic.StoreInt64(&b.latencyMax, 0)
+	atomic.StoreInt64(&b.key, key)
+}
+
+// add adds the counters of another bucket
+func (b *bucket) add(other *bucket) {
+	for i := range b.counts {
+		atomic.AddInt64(&b.counts[i], atomic.LoadInt64(&other.counts[i]))
+	}
+	atomic.AddInt64(&b.latencies, atomic.LoadInt64(&other.latencies))
+	atomic.AddInt64(&b.latencyTotal, atomic.LoadInt64(&other.latencyTotal))
+	b.maxLatency(atomic.LoadInt64(&other.latencyMax))
+}
+
+// maxLatency raises the maximum latency of the bucket to latency
+func (b *bucket) maxLatency(latency int64) {
+	for {
+		max := atomic.LoadInt64(&b.latencyMax)
+		if latency <= max || atomic.CompareAndSwapInt64(&b.latencyMax, max, latency) {
+			return
+		}
+	}
+}
+
+// key returns the index of the bucket containing timestamp since the Unix epoch
+func (r *ring) key(timestamp time.Time) int64 {
+	nano := timestamp.UnixNano()
+	key := nano / r.width
+	if nano < 0 && nano%r.width != 0 {
+		key--
+	}
+	return key
+}
+
+func (r *ring) index(key int64) int {
+	size := int64(len(r.buckets))
+	return int(((key % size) + size) % size)
+}
+
+// expired determines whether a bucket key has fallen out of the window.
+// The window holds the current bucket and the len(buckets)-1 before it
+func (r *ring) expired(key int64, now time.Time) bool {
+	return key <= r.key(now)-int64(len(r.buckets))
+}
+
+// summary aggregates the buckets in the window
+func (r *ring) summary(now time.Time) Summary {
+	var counts [numMetricTypes]int64
+	var latencies, latencyTotal, latencyMax int64
+
+	for i := range r.buckets {
+		b := &r.buckets[i]
+		if r.expired(atomic.LoadInt64(&b.key), now) {
+			continue
+		}
+		for j := range counts {
+			counts[j] += atomic.LoadInt64(&b.counts[j])
+		}
+		latencies += atomic.LoadInt64(&b.latencies)
+		latencyTotal += atomic.LoadInt64(&b.latencyTotal)
+		if max := atomic.LoadInt64(&b.latencyMax); max > latencyMax {
+			latencyMax = max
+		}
+	}
+
+	start := (r.key(now) - int64(len(r.buckets)) + 1) * r.width
+
+	return Summary{
+		Start:         time.Unix(0, start).In(now.Location()),
+		End:           now,
+		Successes:     counts[Success-1],
+		Errors:        counts[Error-1],
+		Timeouts:      counts[Timeout-1],
+		Rejections:    counts[Rejection-1],
+		Cancellations: counts[Cancellation-1],
+		Latencies:     latencies,
+		LatencyTotal:  time.Duration(latencyTotal),
+		LatencyMax:    time.Duration(latencyMax),
+	}
+}
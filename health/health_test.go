@@ -0,0 +1,717 @@
+package health
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+type metric struct {
+	timestamp time.Time
+	metric    MetricType
+}
+
+func TestHealth_index(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		key  int64
+		want int
+	}{
+		{
+			name: "maps a key into the ring",
+			size: 10,
+			key:  946728003,
+			want: 3,
+		},
+		{
+			name: "wraps a key around the ring",
+			size: 10,
+			key:  946728010,
+			want: 0,
+		},
+		{
+			name: "maps a negative key into the ring",
+			size: 10,
+			key:  -3,
+			want: 7,
+		},
+		{
+			name: "maps every key to a single bucket",
+			size: 1,
+			key:  946728003,
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ring{buckets: make([]bucket, tt.size)}
+			if got := r.index(tt.key); got != tt.want {
+				t.Errorf("index() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		wantBuckets int
+		wantWidth   time.Duration
+	}{
+		{
+			name:        "defaults to one second buckets",
+			config:      Config{WindowSize: 10 * time.Second},
+			wantBuckets: 10,
+			wantWidth:   time.Second,
+		},
+		{
+			name:        "uses sub-second buckets",
+			config:      Config{WindowSize: 10 * time.Second, BucketSize: 100 * time.Millisecond},
+			wantBuckets: 100,
+			wantWidth:   100 * time.Millisecond,
+		},
+		{
+			name:        "rounds the window up to a whole bucket",
+			config:      Config{WindowSize: 2500 * time.Millisecond},
+			wantBuckets: 3,
+			wantWidth:   time.Second,
+		},
+		{
+			name:        "shrinks buckets larger than the window",
+			config:      Config{WindowSize: 500 * time.Millisecond},
+			wantBuckets: 1,
+			wantWidth:   500 * time.Millisecond,
+		},
+		{
+			name:        "uses a single bucket for an empty window",
+			config:      Config{},
+			wantBuckets: 1,
+			wantWidth:   time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.config, nil).load()
+			if len(r.buckets) != tt.wantBuckets {
+				t.Errorf("New() buckets = %v, want %v", len(r.buckets), tt.wantBuckets)
+			}
+			if time.Duration(r.width) != tt.wantWidth {
+				t.Errorf("New() width = %v, want %v", time.Duration(r.width), tt.wantWidth)
+			}
+		})
+	}
+}
+
+func TestHealth_key(t *testing.T) {
+	tests := []struct {
+		name      string
+		width     time.Duration
+		timestamp time.Time
+		want      int64
+	}{
+		{
+			name:      "uses unix seconds for one second buckets",
+			width:     time.Second,
+			timestamp: time.Date(2000, 1, 1, 12, 0, 0, 999, time.UTC),
+			want:      946728000,
+		},
+		{
+			name:      "uses sub-second buckets",
+			width:     100 * time.Millisecond,
+			timestamp: time.Date(2000, 1, 1, 12, 0, 0, 250*int(time.Millisecond), time.UTC),
+			want:      9467280002,
+		},
+		{
+			name:      "rounds timestamps before the epoch down",
+			width:     time.Second,
+			timestamp: time.Unix(-1, 500),
+			want:      -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ring{width: int64(tt.width)}
+			if got := r.key(tt.timestamp); got != tt.want {
+				t.Errorf("key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealth_AddMetric(t *testing.T) {
+	type args struct {
+		timestamp time.Time
+		metric    MetricType
+	}
+	tests := []struct {
+		name        string
+		config      Config
+		existing    []metric
+		args        args
+		wantErr     bool
+		wantKeys    []int64
+		wantMetrics map[int64]map[MetricType]int64
+	}{
+		{
+			name:   "can add a success metric to an empty window",
+			config: Config{WindowSize: 10 * time.Second},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+				metric:    Success,
+			},
+			wantErr:  false,
+			wantKeys: []int64{946728000},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728000: map[MetricType]int64{
+					Success: 1,
+				},
+			},
+		},
+		{
+			name:   "can add a success metric to an existing bucket",
+			config: Config{WindowSize: 10 * time.Second},
+			existing: []metric{
+				{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success},
+			},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+				metric:    Success,
+			},
+			wantErr:  false,
+			wantKeys: []int64{946728000},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728000: map[MetricType]int64{
+					Success: 2,
+				},
+			},
+		},
+		{
+			name:   "can add an error metric to an existing bucket",
+			config: Config{WindowSize: 10 * time.Second},
+			existing: []metric{
+				{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success},
+			},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+				metric:    Error,
+			},
+			wantErr:  false,
+			wantKeys: []int64{946728000},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728000: map[MetricType]int64{
+					Success: 1,
+					Error:   1,
+				},
+			},
+		},
+		{
+			name:   "can add a metric between existing buckets",
+			config: Config{WindowSize: 10 * time.Second},
+			existing: []metric{
+				{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success},
+				{time.Date(2000, 1, 1, 12, 0, 2, 0, time.UTC), Error},
+			},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+				metric:    Error,
+			},
+			wantErr:  false,
+			wantKeys: []int64{946728000, 946728001, 946728002},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728000: map[MetricType]int64{
+					Success: 1,
+				},
+				946728001: map[MetricType]int64{
+					Error: 1,
+				},
+				946728002: map[MetricType]int64{
+					Error: 1,
+				},
+			},
+		},
+		{
+			name:   "reuses a bucket once the ring wraps around",
+			config: Config{WindowSize: 2 * time.Second},
+			existing: []metric{
+				{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success},
+				{time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC), Success},
+			},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 2, 0, time.UTC),
+				metric:    Error,
+			},
+			wantErr:  false,
+			wantKeys: []int64{946728001, 946728002},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728001: map[MetricType]int64{
+					Success: 1,
+				},
+				946728002: map[MetricType]int64{
+					Error: 1,
+				},
+			},
+		},
+		{
+			name:   "drops metrics older than the ring",
+			config: Config{WindowSize: 2 * time.Second},
+			existing: []metric{
+				{time.Date(2000, 1, 1, 12, 0, 2, 0, time.UTC), Success},
+			},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+				metric:    Error,
+			},
+			wantErr:  false,
+			wantKeys: []int64{946728002},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728002: map[MetricType]int64{
+					Success: 1,
+				},
+			},
+		},
+		{
+			name:   "returns an error for invalid metric types",
+			config: Config{WindowSize: 10 * time.Second},
+			existing: []metric{
+				{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success},
+			},
+			args: args{
+				timestamp: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+				metric:    -1,
+			},
+			wantErr:  true,
+			wantKeys: []int64{946728000},
+			wantMetrics: map[int64]map[MetricType]int64{
+				946728000: map[MetricType]int64{
+					Success: 1,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.config, nil)
+			for _, m := range tt.existing {
+				c.AddMetric(m.timestamp, m.metric)
+			}
+
+			if err := c.AddMetric(tt.args.timestamp, tt.args.metric); (err != nil) != tt.wantErr {
+				t.Errorf("Health.AddMetric() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			metrics, keys := bucketMetrics(c, time.Date(2000, 1, 1, 12, 0, 2, 0, time.UTC))
+
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("AddMetric() keys = %v, want %v", keys, tt.wantKeys)
+			}
+
+			if !reflect.DeepEqual(metrics, tt.wantMetrics) {
+				t.Errorf("AddMetric() metrics = %v, want %v", metrics, tt.wantMetrics)
+			}
+
+		})
+	}
+}
+
+func TestHealth_Healthy(t *testing.T) {
+	type fields struct {
+		metrics []metric
+		config  Config
+		policy  HealthPolicy
+		now     time.Time
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   bool
+	}{
+		{
+			name: "default algorithm can correctly detect a healthy system",
+			fields: fields{
+				metrics: append(
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success, 100),
+					metric{time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC), Error},
+				),
+				config: Config{
+					WindowSize:               time.Minute,
+					ErrorPercentageThreshold: 0.1,
+				},
+				now: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+			},
+			want: true,
+		},
+		{
+			name: "default algorithm can correctly detect an unhealthy system",
+			fields: fields{
+				metrics: concat(
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success, 5),
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Error, 2),
+					repeat(time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC), Success, 2),
+					repeat(time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC), Error, 5),
+				),
+				config: Config{
+					WindowSize:               time.Minute,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+			},
+			want: false,
+		},
+		{
+			name: "correctly ignores expired buckets",
+			fields: fields{
+				metrics: concat(
+					repeat(time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC), Error, 999),
+					repeat(time.Date(2020, 1, 30, 12, 59, 59, 0, time.UTC), Success, 5),
+					repeat(time.Date(2020, 1, 30, 12, 59, 59, 0, time.UTC), Error, 1),
+					repeat(time.Date(2020, 1, 30, 13, 0, 0, 0, time.UTC), Success, 10),
+					repeat(time.Date(2020, 1, 30, 13, 0, 0, 0, time.UTC), Error, 1),
+				),
+				config: Config{
+					WindowSize:               time.Minute,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: time.Date(2020, 1, 30, 13, 0, 0, 0, time.UTC),
+			},
+			want: true,
+		},
+		{
+			name: "expires sub-second buckets",
+			fields: fields{
+				metrics: concat(
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 50*int(time.Millisecond), time.UTC), Error, 10),
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 950*int(time.Millisecond), time.UTC), Success, 1),
+				),
+				config: Config{
+					WindowSize:               time.Second,
+					BucketSize:               100 * time.Millisecond,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+			},
+			want: true,
+		},
+		{
+			name: "keeps sub-second buckets inside the window",
+			fields: fields{
+				metrics: concat(
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 150*int(time.Millisecond), time.UTC), Error, 10),
+					repeat(time.Date(2000, 1, 1, 12, 0, 0, 950*int(time.Millisecond), time.UTC), Success, 1),
+				),
+				config: Config{
+					WindowSize:               time.Second,
+					BucketSize:               100 * time.Millisecond,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+			},
+			want: false,
+		},
+		{
+			name: "default policy is healthy without requests",
+			fields: fields{
+				config: Config{
+					WindowSize:               time.Minute,
+					ErrorPercentageThreshold: 0.5,
+				},
+				now: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+			},
+			want: true,
+		},
+		{
+			name: "passes the window summary to a custom policy",
+			fields: fields{
+				metrics: []metric{
+					{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Success},
+					{time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC), Error},
+					{time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC), Rejection},
+				},
+				config: Config{
+					WindowSize: time.Minute,
+				},
+				policy: PolicyFunc(func(summary Summary) bool {
+					return reflect.DeepEqual(summary, Summary{
+						Start:      time.Date(2000, 1, 1, 11, 59, 2, 0, time.UTC),
+						End:        time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+						Successes:  1,
+						Errors:     1,
+						Rejections: 1,
+					})
+				}),
+				now: time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			config := tt.fields.config
+			config.Clock = clocktest.NewClock(tt.fields.now)
+
+			c := New(config, tt.fields.policy)
+			for _, m := range tt.fields.metrics {
+				c.AddMetric(m.timestamp, m.metric)
+			}
+
+			if got := c.Healthy(); got != tt.want {
+				t.Errorf("Health.Healthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealth_AddLatency(t *testing.T) {
+	now := time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC)
+
+	c := New(Config{WindowSize: 2 * time.Second}, nil)
+	c.AddLatency(now.Add(-2*time.Second), time.Hour)
+	c.AddLatency(now.Add(-time.Second), 100*time.Millisecond)
+	c.AddLatency(now, 300*time.Millisecond)
+
+	if err := c.AddLatency(now, -1); err == nil {
+		t.Errorf("Health.AddLatency() error = %v, wantErr %v", err, true)
+	}
+
+	summary := c.load().summary(now)
+	if summary.Latencies != 2 {
+		t.Errorf("Health.AddLatency() latencies = %v, want %v", summary.Latencies, 2)
+	}
+	if summary.MeanLatency() != 200*time.Millisecond {
+		t.Errorf("Health.AddLatency() mean = %v, want %v", summary.MeanLatency(), 200*time.Millisecond)
+	}
+	if summary.LatencyMax != 300*time.Millisecond {
+		t.Errorf("Health.AddLatency() max = %v, want %v", summary.LatencyMax, 300*time.Millisecond)
+	}
+}
+
+// bucketMetrics builds a map of the counters of the buckets in the window, keyed by bucket index
+func bucketMetrics(c *Health, now time.Time) (map[int64]map[MetricType]int64, []int64) {
+	metrics := map[int64]map[MetricType]int64{}
+	keys := []int64{}
+
+	r := c.load()
+	for _, b := range r.buckets {
+		if r.expired(b.key, now) {
+			continue
+		}
+
+		values := map[MetricType]int64{}
+		for j, count := range b.counts {
+			if count > 0 {
+				values[MetricType(j+1)] = count
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		metrics[b.key] = values
+		keys = append(keys, b.key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return metrics, keys
+}
+
+func repeat(timestamp time.Time, metricType MetricType, n int) []metric {
+	metrics := make([]metric, n)
+	for i := range metrics {
+		metrics[i] = metric{timestamp, metricType}
+	}
+	return metrics
+}
+
+func concat(metrics ...[]metric) []metric {
+	var all []metric
+	for _, m := range metrics {
+		all = append(all, m...)
+	}
+	return all
+}
+
+func TestHealth_Reset(t *testing.T) {
+	now := time.Date(2000, 1, 1, 12, 0, 1, 0, time.UTC)
+
+	c := New(Config{WindowSize: time.Minute, Clock: clocktest.NewClock(now)}, nil)
+	c.AddMetric(now, Error)
+	c.AddLatency(now, time.Second)
+
+	if got := c.Stats(); got.Errors != 1 || got.Latencies != 1 {
+		t.Errorf("Health.Stats() = %+v, want one error and latency", got)
+	}
+
+	c.Reset()
+	if got := c.Stats(); got.Requests() != 0 || got.Latencies != 0 {
+		t.Errorf("Health.Stats() after Reset() = %+v, want no metrics", got)
+	}
+
+	c.AddMetric(now, Success)
+	if got := c.Stats(); got.Successes != 1 {
+		t.Errorf("Health.Stats() = %+v, want one success", got)
+	}
+}
+
+func TestHealth_Reconfigure(t *testing.T) {
+	now := time.Date(2000, 1, 1, 12, 0, 9, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		config      Config
+		wantSummary Summary
+		wantHealthy bool
+	}{
+		{
+			name: "keeps every metric when the window grows",
+			config: Config{
+				WindowSize:               time.Minute,
+				ErrorPercentageThreshold: 0.5,
+			},
+			wantSummary: Summary{
+				Start:     time.Date(2000, 1, 1, 11, 59, 10, 0, time.UTC),
+				End:       now,
+				Successes: 5,
+				Errors:    5,
+			},
+			wantHealthy: false,
+		},
+		{
+			name: "drops metrics outside a smaller window",
+			config: Config{
+				WindowSize:               5 * time.Second,
+				ErrorPercentageThreshold: 0.5,
+			},
+			wantSummary: Summary{
+				Start:     time.Date(2000, 1, 1, 12, 0, 5, 0, time.UTC),
+				End:       now,
+				Successes: 5,
+			},
+			wantHealthy: true,
+		},
+		{
+			name: "applies a new threshold",
+			config: Config{
+				WindowSize:               10 * time.Second,
+				ErrorPercentageThreshold: 0.6,
+			},
+			wantSummary: Summary{
+				Start:     time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC),
+				End:       now,
+				Successes: 5,
+				Errors:    5,
+			},
+			wantHealthy: true,
+		},
+		{
+			name: "moves metrics into larger buckets",
+			config: Config{
+				WindowSize:               8 * time.Second,
+				BucketSize:               4 * time.Second,
+				ErrorPercentageThreshold: 0.5,
+			},
+			wantSummary: Summary{
+				Start:     time.Date(2000, 1, 1, 12, 0, 4, 0, time.UTC),
+				End:       now,
+				Successes: 5,
+				Errors:    1,
+			},
+			wantHealthy: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Config{
+				WindowSize:               10 * time.Second,
+				ErrorPercentageThreshold: 0.5,
+				Clock:                    clocktest.NewClock(now),
+			}, nil)
+
+			// errors in the first five seconds, successes in the last five
+			for i := 0; i < 10; i++ {
+				metricType := Error
+				if i >= 5 {
+					metricType = Success
+				}
+				c.AddMetric(now.Add(time.Duration(i-9)*time.Second), metricType)
+			}
+
+			c.Reconfigure(tt.config)
+
+			if got := c.Stats(); got != tt.wantSummary {
+				t.Errorf("Health.Stats() = %+v, want %+v", got, tt.wantSummary)
+			}
+			if got := c.Healthy(); got != tt.wantHealthy {
+				t.Errorf("Health.Healthy() = %v, want %v", got, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestHealth_Reconfigure_BucketSize(t *testing.T) {
+	c := New(Config{
+		WindowSize: time.Second,
+		BucketSize: 100 * time.Millisecond,
+		Clock:      clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)),
+	}, nil)
+
+	c.Reconfigure(Config{WindowSize: 2 * time.Second})
+	if got, want := c.load().width, int64(100*time.Millisecond); got != want {
+		t.Errorf("Health.Reconfigure() bucket width = %v, want %v", time.Duration(got), time.Duration(want))
+	}
+	if got, want := len(c.load().buckets), 20; got != want {
+		t.Errorf("Health.Reconfigure() buckets = %v, want %v", got, want)
+	}
+
+	c.Reconfigure(Config{WindowSize: 2 * time.Second, BucketSize: time.Second})
+	if got, want := c.load().width, int64(time.Second); got != want {
+		t.Errorf("Health.Reconfigure() bucket width = %v, want %v", time.Duration(got), time.Duration(want))
+	}
+}
+
+func TestHealth_Reconfigure_Concurrent(t *testing.T) {
+	now := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := New(Config{WindowSize: time.Minute, Clock: clocktest.NewClock(now)}, nil)
+
+	const writers = 4
+	var added int64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.AddMetric(now, Success)
+				c.AddLatency(now, time.Millisecond)
+				atomic.AddInt64(&added, 1)
+			}
+		}()
+	}
+
+	for i := 0; i < 10000; i++ {
+		c.Reconfigure(Config{WindowSize: time.Duration(1+i%2) * time.Minute})
+	}
+	close(stop)
+	wg.Wait()
+
+	stats := c.Stats()
+	if got, want := stats.Successes, atomic.LoadInt64(&added); got != want {
+		t.Errorf("Health.AddMetric() successes = %v, want %v", got, want)
+	}
+	if got, want := stats.Latencies, atomic.LoadInt64(&added); got != want {
+		t.Errorf("Health.AddLatency() latencies = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package health
+
+import "time"
+
+// HealthPolicy decides whether a system is healthy from a summary of its health window
+type HealthPolicy interface {
+	Healthy(summary Summary) bool
+}
+
+// PolicyFunc adapts a function to a HealthPolicy
+type PolicyFunc func(summary Summary) bool
+
+// Healthy ...
+func (f PolicyFunc) Healthy(summary Summary) bool {
+	return f(summary)
+}
+
+// ErrorPercentage is healthy while the ratio of errors and timeouts to requests is below threshold.
+// A window without requests is healthy
+func ErrorPercentage(threshold float64) HealthPolicy {
+	return PolicyFunc(func(summary Summary) bool {
+		if summary.Requests() == 0 {
+			return true
+		}
+		return summary.ErrorPercentage() < threshold
+	})
+}
+
+// MeanLatency is healthy while the mean latency of the window is below threshold
+func MeanLatency(threshold time.Duration) HealthPolicy {
+	return PolicyFunc(func(summary Summary) bool {
+		return summary.MeanLatency() < threshold
+	})
+}
+
+// RequestVolumeBelow is healthy while the window holds fewer than volume requests.
+// Combine it with Or to ignore other policies until there is enough traffic to judge
+func RequestVolumeBelow(volume int64) HealthPolicy {
+	return PolicyFunc(func(summary Summary) bool {
+		return summary.Requests() < volume
+	})
+}
+
+// And is healthy when every policy is healthy
+func And(policies ...HealthPolicy) HealthPolicy {
+	return PolicyFunc(func(summary Summary) bool {
+		for _, policy := range policies {
+			if !policy.Healthy(summary) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Or is healthy when any policy is healthy
+func Or(policies ...HealthPolicy) HealthPolicy {
+	return PolicyFunc(func(summary Summary) bool {
+		for _, policy := range policies {
+			if policy.Healthy(summary) {
+				return true
+			}
+		}
+		return false
+	})
+}
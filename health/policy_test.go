@@ -0,0 +1,104 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthPolicy(t *testing.T) {
+	healthy := PolicyFunc(func(Summary) bool { return true })
+	unhealthy := PolicyFunc(func(Summary) bool { return false })
+
+	tests := []struct {
+		name    string
+		policy  HealthPolicy
+		summary Summary
+		want    bool
+	}{
+		{
+			name:    "error percentage is healthy below the threshold",
+			policy:  ErrorPercentage(0.5),
+			summary: Summary{Successes: 5, Errors: 2, Timeouts: 2},
+			want:    true,
+		},
+		{
+			name:    "error percentage is unhealthy at the threshold",
+			policy:  ErrorPercentage(0.5),
+			summary: Summary{Successes: 4, Errors: 2, Timeouts: 2},
+			want:    false,
+		},
+		{
+			name:    "error percentage counts rejections as requests",
+			policy:  ErrorPercentage(0.5),
+			summary: Summary{Errors: 2, Rejections: 3},
+			want:    true,
+		},
+		{
+			name:    "error percentage is healthy without requests",
+			policy:  ErrorPercentage(0),
+			summary: Summary{},
+			want:    true,
+		},
+		{
+			name:    "mean latency is healthy below the threshold",
+			policy:  MeanLatency(time.Second),
+			summary: Summary{Latencies: 2, LatencyTotal: time.Second},
+			want:    true,
+		},
+		{
+			name:    "mean latency is unhealthy at the threshold",
+			policy:  MeanLatency(time.Second),
+			summary: Summary{Latencies: 2, LatencyTotal: 2 * time.Second},
+			want:    false,
+		},
+		{
+			name:    "request volume is healthy below the volume",
+			policy:  RequestVolumeBelow(10),
+			summary: Summary{Errors: 9},
+			want:    true,
+		},
+		{
+			name:    "and is healthy when every policy is healthy",
+			policy:  And(healthy, healthy),
+			summary: Summary{},
+			want:    true,
+		},
+		{
+			name:    "and is unhealthy when any policy is unhealthy",
+			policy:  And(healthy, unhealthy),
+			summary: Summary{},
+			want:    false,
+		},
+		{
+			name:    "or is healthy when any policy is healthy",
+			policy:  Or(unhealthy, healthy),
+			summary: Summary{},
+			want:    true,
+		},
+		{
+			name:    "or is unhealthy when every policy is unhealthy",
+			policy:  Or(unhealthy, unhealthy),
+			summary: Summary{},
+			want:    false,
+		},
+		{
+			name:    "combinators ignore errors until there is enough traffic",
+			policy:  Or(RequestVolumeBelow(20), And(ErrorPercentage(0.5), MeanLatency(time.Second))),
+			summary: Summary{Errors: 19},
+			want:    true,
+		},
+		{
+			name:    "combinators judge errors once there is enough traffic",
+			policy:  Or(RequestVolumeBelow(20), And(ErrorPercentage(0.5), MeanLatency(time.Second))),
+			summary: Summary{Errors: 20},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Healthy(tt.summary); got != tt.want {
+				t.Errorf("HealthPolicy.Healthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package health
+
+import "time"
+
+// Summary is a read-only aggregate of the metrics recorded in a health window
+type Summary struct {
+	// the bounds of the window the metrics were recorded in
+	Start time.Time
+	End   time.Time
+
+	Successes  int64
+	Errors     int64
+	Timeouts   int64
+	Rejections int64
+
+	// calls abandoned by their caller, counted in the request volume but not in the error percentage
+	Cancellations int64
+
+	// the number, total and maximum of the latencies recorded in the window
+	Latencies    int64
+	LatencyTotal time.Duration
+	LatencyMax   time.Duration
+}
+
+// Count returns the number of metrics of a MetricType
+func (s Summary) Count(metricType MetricType) int64 {
+	switch metricType {
+	case Success:
+		return s.Successes
+	case Error:
+		return s.Errors
+	case Timeout:
+		return s.Timeouts
+	case Rejection:
+		return s.Rejections
+	case Cancellation:
+		return s.Cancellations
+	}
+	return 0
+}
+
+// Requests returns the request volume of the window
+func (s Summary) Requests() int64 {
+	return s.Successes + s.Errors + s.Timeouts + s.Rejections + s.Cancellations
+}
+
+// Failures returns the number of errors and timeouts
+func (s Summary) Failures() int64 {
+	return s.Errors + s.Timeouts
+}
+
+// ErrorPercentage returns the ratio of failures to requests that were not cancelled, zero when there were none
+func (s Summary) ErrorPercentage() float64 {
+	requests := s.Requests() - s.Cancellations
+	if requests == 0 {
+		return 0
+	}
+	return float64(s.Failures()) / float64(requests)
+}
+
+// MeanLatency returns the mean of the latencies recorded in the window
+func (s Summary) MeanLatency() time.Duration {
+	if s.Latencies == 0 {
+		return 0
+	}
+	return s.LatencyTotal / time.Duration(s.Latencies)
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	summary := Summary{
+		Successes:     5,
+		Errors:        2,
+		Timeouts:      1,
+		Rejections:    2,
+		Cancellations: 2,
+		Latencies:     4,
+		LatencyTotal:  2 * time.Second,
+	}
+
+	for metricType, want := range map[MetricType]int64{Success: 5, Error: 2, Timeout: 1, Rejection: 2, Cancellation: 2, -1: 0} {
+		if got := summary.Count(metricType); got != want {
+			t.Errorf("Summary.Count(%v) = %v, want %v", metricType, got, want)
+		}
+	}
+	if got := summary.Requests(); got != 12 {
+		t.Errorf("Summary.Requests() = %v, want %v", got, 12)
+	}
+	if got := summary.Failures(); got != 3 {
+		t.Errorf("Summary.Failures() = %v, want %v", got, 3)
+	}
+	if got := summary.ErrorPercentage(); got != 0.3 {
+		t.Errorf("Summary.ErrorPercentage() = %v, want %v", got, 0.3)
+	}
+	if got := summary.MeanLatency(); got != 500*time.Millisecond {
+		t.Errorf("Summary.MeanLatency() = %v, want %v", got, 500*time.Millisecond)
+	}
+	if got := (Summary{}).ErrorPercentage(); got != 0 {
+		t.Errorf("Summary.ErrorPercentage() = %v, want %v", got, 0)
+	}
+}
@@ -0,0 +1,163 @@
+package circuitbreaker
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// defaults of HedgeConfig
+const (
+	DefaultHedgeAttempts = 2
+	hedgeSamples         = 100
+	hedgeMinSamples      = 10
+)
+
+// HedgeConfig ...
+type HedgeConfig struct {
+	// the time to wait for an attempt before starting another
+	Delay time.Duration
+
+	// when set, the delay becomes this percentile of the latencies of recent successful attempts, such as 0.95,
+	// once enough of them have been observed. Delay is used until then
+	Percentile float64
+
+	// the number of attempts including the first, defaults to DefaultHedgeAttempts
+	MaxAttempts int
+
+	// the source of the timers, defaults to the system clock
+	Clock clock.Clock
+}
+
+// HedgePolicy reduces tail latency by starting another attempt when the previous one has not completed within a delay.
+// The first attempt to succeed wins and the context of the others is cancelled. It must only wrap idempotent operations.
+// Wrapping a CircuitBreaker records every attempt. The cancelled attempts are recorded as health.Cancellation,
+// which counts toward the request volume but not the error percentage
+type HedgePolicy struct {
+	config HedgeConfig
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+// NewHedgePolicy ...
+func NewHedgePolicy(config HedgeConfig) *HedgePolicy {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultHedgeAttempts
+	}
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+
+	return &HedgePolicy{
+		config: config,
+	}
+}
+
+// Execute returns the result of the first attempt to succeed, or the error of the last attempt when they all fail.
+// A failed attempt does not start another one early
+func (h *HedgePolicy) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		result  interface{}
+		err     error
+		latency time.Duration
+	}
+
+	results := make(chan outcome, h.config.MaxAttempts)
+	launch := func() {
+		start := h.config.Clock.Now()
+		go func() {
+			result, err := operation(ctx)
+			results <- outcome{result, err, h.config.Clock.Now().Sub(start)}
+		}()
+	}
+
+	delay := h.Delay()
+	launch()
+	launched, outstanding := 1, 1
+
+	var hedge <-chan time.Time
+	if launched < h.config.MaxAttempts {
+		timer := h.config.Clock.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C()
+	}
+
+	for {
+		select {
+		case o := <-results:
+			outstanding--
+			if o.err == nil {
+				h.observe(o.latency)
+				return o.result, nil
+			}
+			if outstanding == 0 {
+				return o.result, o.err
+			}
+
+		case <-hedge:
+			launch()
+			launched++
+			outstanding++
+			hedge = nil
+			if launched < h.config.MaxAttempts {
+				timer := h.config.Clock.NewTimer(delay)
+				defer timer.Stop()
+				hedge = timer.C()
+			}
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Delay returns the time to wait before starting another attempt
+func (h *HedgePolicy) Delay() time.Duration {
+	if h.config.Percentile <= 0 {
+		return h.config.Delay
+	}
+
+	h.mu.Lock()
+	latencies := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+
+	if len(latencies) < hedgeMinSamples {
+		return h.config.Delay
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	i := int(math.Ceil(math.Min(h.config.Percentile, 1)*float64(len(latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return latencies[i]
+}
+
+// observe adds the latency of a successful attempt to the recent latencies
+func (h *HedgePolicy) observe(latency time.Duration) {
+	if h.config.Percentile <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeSamples
+}
@@ -0,0 +1,99 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestHedgePolicy_Execute(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithClock(clock))
+	h := NewHedgePolicy(HedgeConfig{Delay: 100 * time.Millisecond, Clock: clock})
+
+	// the first attempt hangs until it is cancelled
+	var attempts int32
+	cancelled := make(chan struct{})
+	operation := func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, fmt.Errorf("calling the dependency: %v", ctx.Err())
+		}
+		return 100, nil
+	}
+
+	done := make(chan interface{})
+	go func() {
+		got, err := Wrap(h, c).Execute(context.Background(), operation)
+		if err != nil {
+			t.Errorf("HedgePolicy.Execute() error = %v", err)
+		}
+		done <- got
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+
+	if got := <-done; got != 100 {
+		t.Errorf("HedgePolicy.Execute() = %v, want %v", got, 100)
+	}
+	<-cancelled
+
+	// the cancelled attempt reached the dependency but is not a failure of it
+	deadline := time.Now().Add(time.Second)
+	for c.health.Stats().Requests() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stats := c.health.Stats()
+	if stats.Successes != 1 || stats.Cancellations != 1 || stats.Failures() != 0 {
+		t.Errorf("HedgePolicy.Execute() stats = %+v, want 1 success, 1 cancellation and no failures", stats)
+	}
+}
+
+func TestHedgePolicy_Execute_Failures(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	h := NewHedgePolicy(HedgeConfig{Delay: 100 * time.Millisecond, Clock: clock})
+
+	// an attempt failing before the delay does not start another one
+	var attempts int32
+	_, err := h.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&attempts, 1)
+		return nil, errors.New("failure")
+	})
+	if err == nil || err.Error() != "failure" {
+		t.Errorf("HedgePolicy.Execute() error = %v, want %v", err, "failure")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("HedgePolicy.Execute() made %v attempts, want %v", got, 1)
+	}
+}
+
+func TestHedgePolicy_Delay(t *testing.T) {
+	h := NewHedgePolicy(HedgeConfig{Delay: time.Second, Percentile: 0.95})
+
+	for i := 1; i <= 9; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	if got := h.Delay(); got != time.Second {
+		t.Errorf("HedgePolicy.Delay() = %v with too few samples, want %v", got, time.Second)
+	}
+
+	h.observe(10 * time.Millisecond)
+	if got := h.Delay(); got != 10*time.Millisecond {
+		t.Errorf("HedgePolicy.Delay() = %v, want %v", got, 10*time.Millisecond)
+	}
+
+	// only the most recent samples are kept
+	for i := 0; i < hedgeSamples; i++ {
+		h.observe(time.Millisecond)
+	}
+	if got := h.Delay(); got != time.Millisecond {
+		t.Errorf("HedgePolicy.Delay() = %v, want %v", got, time.Millisecond)
+	}
+}
@@ -0,0 +1,530 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// trip strategies understood by configuration files
+const (
+	TripStrategyErrorPercentage = "error_percentage"
+	TripStrategyEWMA            = "ewma"
+)
+
+// FileError describes a problem at a line of a configuration file
+type FileError struct {
+	File    string
+	Line    int
+	Breaker string
+	Err     error
+}
+
+func (e *FileError) Error() string {
+	if e.Breaker != "" {
+		return fmt.Sprintf("%s:%d: breaker %q: %v", e.File, e.Line, e.Breaker, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+// LoadError lists every problem found in a configuration file
+type LoadError struct {
+	Errors []*FileError
+}
+
+func (e *LoadError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// breakerFile is a breaker section of a configuration file.
+// Fields left out of the file keep the defaults and the options given to the loader
+type breakerFile struct {
+	name  string
+	line  int
+	lines map[string]int
+
+	SleepWindow      *time.Duration
+	Multiplier       *float64
+	MaxSleepWindow   *time.Duration
+	Jitter           *float64
+	Window           *time.Duration
+	BucketSize       *time.Duration
+	ErrorThreshold   *float64
+	TripStrategy     string
+	HalfLife         time.Duration
+	LatencyThreshold time.Duration
+	Timeout          *time.Duration
+	RampUp           *time.Duration
+	RampUpCurve      string
+}
+
+// breakerFields decodes the keys of a breaker section
+var breakerFields = map[string]func(b *breakerFile, value *yaml.Node) error{
+	"sleep_window": func(b *breakerFile, value *yaml.Node) error {
+		return decodeDuration(value, &b.SleepWindow)
+	},
+	"sleep_window_multiplier": func(b *breakerFile, value *yaml.Node) error {
+		return decodeFloat(value, &b.Multiplier)
+	},
+	"max_sleep_window": func(b *breakerFile, value *yaml.Node) error {
+		return decodeDuration(value, &b.MaxSleepWindow)
+	},
+	"sleep_window_jitter": func(b *breakerFile, value *yaml.Node) error {
+		return decodeFloat(value, &b.Jitter)
+	},
+	"window": func(b *breakerFile, value *yaml.Node) error {
+		return decodeDuration(value, &b.Window)
+	},
+	"bucket_size": func(b *breakerFile, value *yaml.Node) error {
+		return decodeDuration(value, &b.BucketSize)
+	},
+	"error_threshold": func(b *breakerFile, value *yaml.Node) error {
+		return decodeFloat(value, &b.ErrorThreshold)
+	},
+	"trip_strategy": func(b *breakerFile, value *yaml.Node) error {
+		return decodeString(value, &b.TripStrategy)
+	},
+	"half_life": func(b *breakerFile, value *yaml.Node) error {
+		var d *time.Duration
+		err := decodeDuration(value, &d)
+		if d != nil {
+			b.HalfLife = *d
+		}
+		return err
+	},
+	"latency_threshold": func(b *breakerFile, value *yaml.Node) error {
+		var d *time.Duration
+		err := decodeDuration(value, &d)
+		if d != nil {
+			b.LatencyThreshold = *d
+		}
+		return err
+	},
+	"timeout": func(b *breakerFile, value *yaml.Node) error {
+		return decodeDuration(value, &b.Timeout)
+	},
+	"ramp_up": func(b *breakerFile, value *yaml.Node) error {
+		return decodeDuration(value, &b.RampUp)
+	},
+	"ramp_up_curve": func(b *breakerFile, value *yaml.Node) error {
+		return decodeString(value, &b.RampUpCurve)
+	},
+}
+
+// configFileKeys maps Config fields to the keys of a breaker section
+var configFileKeys = map[string]string{
+	"SleepWindow":                    "sleep_window",
+	"SleepWindowMillisenconds":       "sleep_window",
+	"SleepWindowMultiplier":          "sleep_window_multiplier",
+	"MaxSleepWindow":                 "max_sleep_window",
+	"SleepWindowJitter":              "sleep_window_jitter",
+	"HealthMetricsWindow":            "window",
+	"HealthMetricsWindowSize":        "window",
+	"HealthMetricsBucketSize":        "bucket_size",
+	"HealthErrorPercentageThreshold": "error_threshold",
+	"Timeout":                        "timeout",
+	"RampUpDuration":                 "ramp_up",
+	"RampUpCurve":                    "ramp_up_curve",
+}
+
+// LoadFile registers the circuit breakers described in a JSON or YAML file.
+// The options are applied to every breaker before the settings from the file
+func (r *Registry) LoadFile(path string, opts ...Option) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return r.Load(path, data, opts...)
+}
+
+// Load registers the circuit breakers described in data, the format is chosen by the extension of name.
+// Nothing is registered unless every breaker in the file is valid
+func (r *Registry) Load(name string, data []byte, opts ...Option) error {
+	files, err := parseFile(name, data)
+	if err != nil {
+		return err
+	}
+
+	var breakers []*CircuitBreaker
+	var errs []*FileError
+
+	for _, b := range files {
+		if _, ok := r.Get(b.name); ok {
+			errs = append(errs, &FileError{File: name, Line: b.line, Breaker: b.name, Err: errors.New("already registered")})
+			continue
+		}
+
+		c, err := b.build(name, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		breakers = append(breakers, c)
+	}
+
+	if len(errs) > 0 {
+		closeAll(breakers)
+		return &LoadError{Errors: errs}
+	}
+
+	for i, c := range breakers {
+		if err := r.Register(c); err != nil {
+			closeAll(breakers[i:])
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReloadFile applies a JSON or YAML file to the registry, see Reload
+func (r *Registry) ReloadFile(path string, opts ...Option) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return r.Reload(path, data, opts...)
+}
+
+// Reload applies the circuit breakers described in data to the registry. Breakers that are already
+// registered keep their state and metrics and take the new config through UpdateConfig, the others are registered.
+// Breakers missing from data are left as they are, and a changed trip_strategy only applies to new breakers.
+// Nothing is changed unless every breaker in the file is valid
+func (r *Registry) Reload(name string, data []byte, opts ...Option) error {
+	files, err := parseFile(name, data)
+	if err != nil {
+		return err
+	}
+
+	var breakers []*CircuitBreaker
+	var errs []*FileError
+
+	for _, b := range files {
+		c, err := b.build(name, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		breakers = append(breakers, c)
+	}
+
+	if len(errs) > 0 {
+		closeAll(breakers)
+		return &LoadError{Errors: errs}
+	}
+
+	for i, c := range breakers {
+		if existing, ok := r.Get(c.Name()); ok {
+			// only the config of the new breaker is used
+			c.Close()
+			if err := existing.UpdateConfig(c.Config()); err != nil {
+				closeAll(breakers[i+1:])
+				return err
+			}
+			continue
+		}
+		if err := r.Register(c); err != nil {
+			closeAll(breakers[i:])
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeAll stops the probes of breakers that were built but are not registered
+func closeAll(breakers []*CircuitBreaker) {
+	for _, c := range breakers {
+		c.Close()
+	}
+}
+
+// build creates the circuit breaker described by a breaker section
+func (b *breakerFile) build(file string, opts []Option) (*CircuitBreaker, *FileError) {
+	fileOpts, err := b.options(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c, newErr := New(b.name, append(append([]Option{}, opts...), fileOpts...)...)
+	if configErr, ok := newErr.(*ConfigError); ok {
+		key, ok := configFileKeys[configErr.Field]
+		if !ok {
+			key = configErr.Field
+		}
+		return nil, b.errorf(file, key, "invalid %s: %s", key, configErr.Message)
+	}
+	if newErr != nil {
+		return nil, &FileError{File: file, Line: b.line, Breaker: b.name, Err: newErr}
+	}
+
+	return c, nil
+}
+
+// options converts a breaker section to options
+func (b *breakerFile) options(file string) ([]Option, *FileError) {
+	var opts []Option
+
+	if b.SleepWindow != nil {
+		opts = append(opts, WithSleepWindow(*b.SleepWindow))
+	}
+	if b.Multiplier != nil {
+		opts = append(opts, func(c *CircuitBreaker) {
+			c.config.SleepWindowMultiplier = *b.Multiplier
+		})
+	}
+	if b.MaxSleepWindow != nil {
+		opts = append(opts, func(c *CircuitBreaker) {
+			c.config.MaxSleepWindow = *b.MaxSleepWindow
+		})
+	}
+	if b.Jitter != nil {
+		opts = append(opts, WithSleepWindowJitter(*b.Jitter))
+	}
+	if b.Window != nil {
+		opts = append(opts, WithHealthMetricsWindow(*b.Window))
+	}
+	if b.BucketSize != nil {
+		opts = append(opts, WithHealthMetricsBucketSize(*b.BucketSize))
+	}
+	if b.ErrorThreshold != nil {
+		opts = append(opts, WithErrorPercentageThreshold(*b.ErrorThreshold))
+	}
+	if b.Timeout != nil {
+		opts = append(opts, WithTimeout(*b.Timeout))
+	}
+
+	curve := LinearRampUp
+	switch b.RampUpCurve {
+	case "", LinearRampUp.String():
+	case ExponentialRampUp.String():
+		curve = ExponentialRampUp
+	default:
+		return nil, b.errorf(file, "ramp_up_curve", "unknown ramp_up_curve %q, must be %s or %s",
+			b.RampUpCurve, LinearRampUp, ExponentialRampUp)
+	}
+	if b.RampUp != nil {
+		opts = append(opts, WithRampUp(*b.RampUp, curve))
+	} else if b.RampUpCurve != "" {
+		return nil, b.errorf(file, "ramp_up_curve", "ramp_up_curve is only valid with ramp_up")
+	}
+
+	switch b.TripStrategy {
+	case "", TripStrategyErrorPercentage:
+		for _, key := range []string{"half_life", "latency_threshold"} {
+			if _, ok := b.lines[key]; ok {
+				return nil, b.errorf(file, key, "%s is only valid with trip_strategy %s", key, TripStrategyEWMA)
+			}
+		}
+	case TripStrategyEWMA:
+		if b.HalfLife < 0 {
+			return nil, b.errorf(file, "half_life", "invalid half_life: must not be negative, got %v", b.HalfLife)
+		}
+		if b.LatencyThreshold < 0 {
+			return nil, b.errorf(file, "latency_threshold", "invalid latency_threshold: must not be negative, got %v", b.LatencyThreshold)
+		}
+		opts = append(opts, WithEWMAHealth(b.HalfLife, b.LatencyThreshold))
+	default:
+		return nil, b.errorf(file, "trip_strategy", "unknown trip_strategy %q, must be %s or %s",
+			b.TripStrategy, TripStrategyErrorPercentage, TripStrategyEWMA)
+	}
+
+	return opts, nil
+}
+
+// errorf reports an error at the line of key, or of the breaker when the key is not in the file
+func (b *breakerFile) errorf(file, key, format string, args ...interface{}) *FileError {
+	line, ok := b.lines[key]
+	if !ok {
+		line = b.line
+	}
+	return &FileError{File: file, Line: line, Breaker: b.name, Err: fmt.Errorf(format, args...)}
+}
+
+// parseFile decodes the breaker sections of a configuration file in the order they appear
+func parseFile(name string, data []byte) ([]*breakerFile, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		// JSON is decoded as YAML for line numbers, but must be valid JSON first
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, &LoadError{Errors: []*FileError{jsonError(name, data, err)}}
+		}
+	case ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration format, use .json, .yaml or .yml", name)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &LoadError{Errors: []*FileError{yamlError(name, err)}}
+	}
+
+	var errs []*FileError
+	var breakers []*breakerFile
+
+	// the line each breaker name was first seen on, decoding keeps duplicate keys
+	lines := map[string]int{}
+
+	document := &root
+	if document.Kind == yaml.DocumentNode && len(document.Content) > 0 {
+		document = document.Content[0]
+	}
+	if document.Kind == 0 {
+		return nil, nil
+	}
+	if document.Kind != yaml.MappingNode {
+		return nil, &LoadError{Errors: []*FileError{{File: name, Line: document.Line, Err: errors.New("expected a mapping with a breakers key")}}}
+	}
+
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		key, value := document.Content[i], document.Content[i+1]
+
+		if key.Value != "breakers" {
+			errs = append(errs, &FileError{File: name, Line: key.Line, Err: fmt.Errorf("unknown field %q", key.Value)})
+			continue
+		}
+
+		if value.Kind != yaml.MappingNode {
+			errs = append(errs, &FileError{File: name, Line: value.Line, Err: errors.New("breakers must be a mapping of names to breakers")})
+			continue
+		}
+
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			key := value.Content[j]
+			if line, ok := lines[key.Value]; ok {
+				errs = append(errs, &FileError{File: name, Line: key.Line, Breaker: key.Value, Err: fmt.Errorf("already defined on line %d", line)})
+				continue
+			}
+			lines[key.Value] = key.Line
+
+			b, breakerErrs := parseBreaker(name, key, value.Content[j+1])
+			errs = append(errs, breakerErrs...)
+			if len(breakerErrs) == 0 {
+				breakers = append(breakers, b)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &LoadError{Errors: errs}
+	}
+
+	return breakers, nil
+}
+
+// parseBreaker decodes a single breaker section
+func parseBreaker(file string, key, value *yaml.Node) (*breakerFile, []*FileError) {
+	b := &breakerFile{
+		name:  key.Value,
+		line:  key.Line,
+		lines: map[string]int{},
+	}
+
+	if b.name == "" {
+		return nil, []*FileError{{File: file, Line: key.Line, Err: errors.New("breaker name must not be empty")}}
+	}
+
+	// an empty section uses the defaults
+	if value.Tag == "!!null" {
+		return b, nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return nil, []*FileError{{File: file, Line: value.Line, Breaker: b.name, Err: errors.New("breaker must be a mapping")}}
+	}
+
+	var errs []*FileError
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		field, fieldValue := value.Content[i], value.Content[i+1]
+
+		decode, ok := breakerFields[field.Value]
+		if !ok {
+			errs = append(errs, &FileError{File: file, Line: field.Line, Breaker: b.name, Err: fmt.Errorf("unknown field %q", field.Value)})
+			continue
+		}
+
+		b.lines[field.Value] = field.Line
+		if err := decode(b, fieldValue); err != nil {
+			errs = append(errs, &FileError{File: file, Line: fieldValue.Line, Breaker: b.name, Err: fmt.Errorf("invalid %s: %v", field.Value, err)})
+		}
+	}
+
+	return b, errs
+}
+
+func decodeDuration(value *yaml.Node, d **time.Duration) error {
+	if value.Kind != yaml.ScalarNode || value.Tag != "!!str" {
+		return fmt.Errorf("expected a duration string such as \"1.5s\", got %q", value.Value)
+	}
+
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("expected a duration string such as \"1.5s\", got %q", value.Value)
+	}
+
+	*d = &parsed
+	return nil
+}
+
+func decodeFloat(value *yaml.Node, f **float64) error {
+	if value.Kind != yaml.ScalarNode || (value.Tag != "!!float" && value.Tag != "!!int") {
+		return fmt.Errorf("expected a number, got %q", value.Value)
+	}
+
+	parsed, err := strconv.ParseFloat(value.Value, 64)
+	if err != nil {
+		return fmt.Errorf("expected a number, got %q", value.Value)
+	}
+
+	*f = &parsed
+	return nil
+}
+
+func decodeString(value *yaml.Node, s *string) error {
+	if value.Kind != yaml.ScalarNode || value.Tag != "!!str" {
+		return fmt.Errorf("expected a string, got %q", value.Value)
+	}
+
+	*s = value.Value
+	return nil
+}
+
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlError extracts the line number from a YAML syntax error
+func yamlError(file string, err error) *FileError {
+	if match := yamlLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return &FileError{File: file, Line: line, Err: errors.New(match[2])}
+	}
+	return &FileError{File: file, Err: err}
+}
+
+// jsonError converts the offset of a JSON syntax error to a line number
+func jsonError(file string, data []byte, err error) *FileError {
+	offset := int64(len(data))
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		offset = syntaxErr.Offset
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	line := 1 + strings.Count(string(data[:offset]), "\n")
+	return &FileError{File: file, Line: line, Err: err}
+}
@@ -0,0 +1,370 @@
+package circuitbreaker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"circuitbreaker/health"
+)
+
+func TestRegistry_Load(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		data        string
+		wantConfigs map[string]Config
+		wantErr     string
+	}{
+		{
+			name: "loads breakers from yaml",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    sleep_window: 10s
+    window: 1m
+    bucket_size: 100ms
+    error_threshold: 0.25
+    timeout: 500ms
+  search:
+`,
+			wantConfigs: map[string]Config{
+				"payments": {
+					SleepWindow:                    10 * time.Second,
+					HealthMetricsWindow:            time.Minute,
+					HealthMetricsBucketSize:        100 * time.Millisecond,
+					HealthErrorPercentageThreshold: 0.25,
+					Timeout:                        500 * time.Millisecond,
+				},
+				"search": DefaultConfig(),
+			},
+		},
+		{
+			name: "loads the sleep window backoff",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    sleep_window_multiplier: 2
+    max_sleep_window: 1m
+    sleep_window_jitter: 0.1
+`,
+			wantConfigs: map[string]Config{
+				"payments": {
+					SleepWindow:                    DefaultSleepWindow,
+					SleepWindowMultiplier:          2,
+					MaxSleepWindow:                 time.Minute,
+					SleepWindowJitter:              0.1,
+					HealthMetricsWindow:            DefaultHealthMetricsWindow,
+					HealthErrorPercentageThreshold: DefaultHealthErrorPercentageThreshold,
+				},
+			},
+		},
+		{
+			name: "loads the ramp up",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    ramp_up: 30s
+    ramp_up_curve: exponential
+`,
+			wantConfigs: map[string]Config{
+				"payments": {
+					SleepWindow:                    DefaultSleepWindow,
+					HealthMetricsWindow:            DefaultHealthMetricsWindow,
+					HealthErrorPercentageThreshold: DefaultHealthErrorPercentageThreshold,
+					RampUpDuration:                 30 * time.Second,
+					RampUpCurve:                    ExponentialRampUp,
+				},
+			},
+		},
+		{
+			name: "rejects unknown ramp up curves",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    ramp_up: 30s
+    ramp_up_curve: quadratic
+`,
+			wantErr: `breakers.yaml:5: breaker "payments": unknown ramp_up_curve "quadratic", must be linear or exponential`,
+		},
+		{
+			name: "loads breakers from json",
+			file: "breakers.json",
+			data: `{
+  "breakers": {
+    "payments": {
+      "sleep_window": "10s",
+      "error_threshold": 1,
+      "trip_strategy": "ewma",
+      "half_life": "30s"
+    }
+  }
+}`,
+			wantConfigs: map[string]Config{
+				"payments": {
+					SleepWindow:                    10 * time.Second,
+					HealthMetricsWindow:            DefaultHealthMetricsWindow,
+					HealthErrorPercentageThreshold: 1,
+				},
+			},
+		},
+		{
+			name: "rejects unknown fields",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    sleep_window: 10s
+    sleep_windw: 10s
+retries: 3
+`,
+			wantErr: "breakers.yaml:5: breaker \"payments\": unknown field \"sleep_windw\"\n" +
+				"breakers.yaml:6: unknown field \"retries\"",
+		},
+		{
+			name: "rejects breakers defined twice",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    window: 1m
+  payments:
+    window: 2m
+`,
+			wantErr: "breakers.yaml:5: breaker \"payments\": already defined on line 3",
+		},
+		{
+			name: "rejects breakers defined twice in json",
+			file: "breakers.json",
+			data: `{
+  "breakers": {
+    "payments": {},
+    "payments": {}
+  }
+}`,
+			wantErr: "breakers.json:4: breaker \"payments\": already defined on line 3",
+		},
+		{
+			name: "reports invalid values with their line",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    sleep_window: 10
+    error_threshold: high
+`,
+			wantErr: "breakers.yaml:4: breaker \"payments\": invalid sleep_window: expected a duration string such as \"1.5s\", got \"10\"\n" +
+				"breakers.yaml:5: breaker \"payments\": invalid error_threshold: expected a number, got \"high\"",
+		},
+		{
+			name: "reports config validation errors with their line",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    window: 1m
+    error_threshold: 50
+  search:
+    timeout: -1s
+`,
+			wantErr: "breakers.yaml:5: breaker \"payments\": invalid error_threshold: must be a ratio between 0 and 1, got 50 (use 0.5 for 50%)\n" +
+				"breakers.yaml:7: breaker \"search\": invalid timeout: must not be negative, got -1s",
+		},
+		{
+			name: "rejects an unknown trip strategy",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    trip_strategy: consecutive
+`,
+			wantErr: "breakers.yaml:4: breaker \"payments\": unknown trip_strategy \"consecutive\", must be error_percentage or ewma",
+		},
+		{
+			name: "rejects ewma fields for the error percentage strategy",
+			file: "breakers.yaml",
+			data: `
+breakers:
+  payments:
+    half_life: 10s
+`,
+			wantErr: "breakers.yaml:4: breaker \"payments\": half_life is only valid with trip_strategy ewma",
+		},
+		{
+			name:    "reports yaml syntax errors with their line",
+			file:    "breakers.yml",
+			data:    "breakers:\n  payments:\n\twindow: 1m\n",
+			wantErr: "breakers.yml:3: found character that cannot start any token",
+		},
+		{
+			name:    "reports json syntax errors with their line",
+			file:    "breakers.json",
+			data:    "{\n  \"breakers\": {\n    \"payments\": {,\n  }\n}",
+			wantErr: "breakers.json:3: invalid character ',' looking for beginning of object key string",
+		},
+		{
+			name:    "rejects unsupported formats",
+			file:    "breakers.toml",
+			data:    "",
+			wantErr: "breakers.toml: unsupported configuration format, use .json, .yaml or .yml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+
+			err := r.Load(tt.file, []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Registry.Load() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(r.Names()) != 0 {
+					t.Errorf("Registry.Load() registered %v after an error", r.Names())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Load() error = %v", err)
+			}
+
+			configs := map[string]Config{}
+			for _, c := range r.List() {
+				config := c.config
+				config.Clock = nil
+				configs[c.Name()] = config
+			}
+			if !reflect.DeepEqual(configs, tt.wantConfigs) {
+				t.Errorf("Registry.Load() configs = %+v, want %+v", configs, tt.wantConfigs)
+			}
+		})
+	}
+}
+
+func TestRegistry_Load_EWMA(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Load("breakers.yaml", []byte("breakers:\n  payments:\n    trip_strategy: ewma\n")); err != nil {
+		t.Fatalf("Registry.Load() error = %v", err)
+	}
+
+	c, _ := r.Get("payments")
+	if _, ok := c.health.(*health.EWMA); !ok {
+		t.Errorf("Registry.Load() health = %T, want %T", c.health, &health.EWMA{})
+	}
+}
+
+func TestRegistry_Load_AlreadyRegistered(t *testing.T) {
+	r := NewRegistry()
+	c, _ := New("payments")
+	r.Register(c)
+
+	err := r.Load("breakers.yaml", []byte("breakers:\n  search:\n  payments:\n"))
+	if want := "breakers.yaml:3: breaker \"payments\": already registered"; err == nil || err.Error() != want {
+		t.Fatalf("Registry.Load() error = %v, want %v", err, want)
+	}
+
+	if _, ok := r.Get("search"); ok {
+		t.Errorf("Registry.Load() registered search after an error")
+	}
+}
+
+func TestRegistry_Reload_Probes(t *testing.T) {
+	probe := WithProbe(time.Hour, func(ctx context.Context) error {
+		return nil
+	})
+
+	r := NewRegistry()
+	if err := r.Load("breakers.yaml", []byte("breakers:\n  payments:\n"), probe); err != nil {
+		t.Fatalf("Registry.Load() error = %v", err)
+	}
+	payments, _ := r.Get("payments")
+	defer payments.Close()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		r.Reload("breakers.yaml", []byte("breakers:\n  payments:\n    window: 1m\n"), probe)
+		r.Reload("breakers.yaml", []byte("breakers:\n  payments:\n  search:\n    error_threshold: 2\n"), probe)
+		r.Load("breakers.yaml", []byte("breakers:\n  search:\n  payments:\n"), probe)
+	}
+
+	// the probes of the breakers built only for their config have stopped
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("Registry.Reload() left %v goroutines running, want %v", got, before)
+	}
+}
+
+func TestRegistry_LoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "circuitbreaker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "breakers.yaml")
+	if err := ioutil.WriteFile(path, []byte("breakers:\n  payments:\n    window: 30s\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry()
+	if err := r.LoadFile(path, WithSleepWindow(time.Minute)); err != nil {
+		t.Fatalf("Registry.LoadFile() error = %v", err)
+	}
+
+	c, ok := r.Get("payments")
+	if !ok {
+		t.Fatalf("Registry.LoadFile() did not register payments")
+	}
+	if c.config.SleepWindow != time.Minute || c.config.HealthMetricsWindow != 30*time.Second {
+		t.Errorf("Registry.LoadFile() config = %+v, want the option and file settings", c.config)
+	}
+
+	if err := r.LoadFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("Registry.LoadFile() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Load("breakers.yaml", []byte("breakers:\n  payments:\n    window: 30s\n")); err != nil {
+		t.Fatalf("Registry.Load() error = %v", err)
+	}
+	payments, _ := r.Get("payments")
+	payments.SetStatus(Open)
+
+	err := r.Reload("breakers.yaml", []byte("breakers:\n  payments:\n    window: 1m\n  search:\n    error_threshold: 2\n"))
+	if _, ok := err.(*LoadError); !ok {
+		t.Fatalf("Registry.Reload() error = %v, want %T", err, &LoadError{})
+	}
+	if got := payments.Config().HealthMetricsWindow; got != 30*time.Second {
+		t.Errorf("Registry.Reload() window = %v after an error, want %v", got, 30*time.Second)
+	}
+
+	if err := r.Reload("breakers.yaml", []byte("breakers:\n  payments:\n    window: 1m\n  search:\n")); err != nil {
+		t.Fatalf("Registry.Reload() error = %v", err)
+	}
+
+	if c, _ := r.Get("payments"); c != payments {
+		t.Errorf("Registry.Reload() replaced payments")
+	}
+	if got := payments.Config().HealthMetricsWindow; got != time.Minute {
+		t.Errorf("Registry.Reload() window = %v, want %v", got, time.Minute)
+	}
+	if got := payments.Status(); got != Open {
+		t.Errorf("Registry.Reload() status = %v, want %v", got, Open)
+	}
+	if _, ok := r.Get("search"); !ok {
+		t.Errorf("Registry.Reload() did not register search")
+	}
+}
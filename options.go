@@ -0,0 +1,145 @@
+package circuitbreaker
+
+import (
+	"math/rand"
+	"time"
+
+	"circuitbreaker/clock"
+	"circuitbreaker/health"
+)
+
+// Option ...
+type Option func(*CircuitBreaker)
+
+// WithConfig replaces the whole Config, later options override its fields
+func WithConfig(config Config) Option {
+	return func(c *CircuitBreaker) {
+		c.config = config
+	}
+}
+
+// WithSleepWindow sets the time to wait before retrying when the circuit is open
+func WithSleepWindow(d time.Duration) Option {
+	return func(c *CircuitBreaker) {
+		c.config.SleepWindow = d
+	}
+}
+
+// WithSleepWindowBackoff grows the sleep window by multiplier each time a HalfOpen probe fails, up to max.
+// The sleep window goes back to its configured length once the circuit closes
+func WithSleepWindowBackoff(multiplier float64, max time.Duration) Option {
+	return func(c *CircuitBreaker) {
+		c.config.SleepWindowMultiplier = multiplier
+		c.config.MaxSleepWindow = max
+	}
+}
+
+// WithSleepWindowJitter randomly lengthens or shortens each sleep window by up to jitter, a ratio between 0 and 1
+func WithSleepWindowJitter(jitter float64) Option {
+	return func(c *CircuitBreaker) {
+		c.config.SleepWindowJitter = jitter
+	}
+}
+
+// WithRampUp gradually restores traffic after a successful probe closes the circuit. The fraction of calls admitted
+// grows along curve over d, the rest are rejected and recorded as health.Rejection, and a failure reopens the circuit
+func WithRampUp(d time.Duration, curve RampUpCurve) Option {
+	return func(c *CircuitBreaker) {
+		c.config.RampUpDuration = d
+		c.config.RampUpCurve = curve
+	}
+}
+
+// WithRandSource sets the source of the sleep window jitter and of the calls admitted while ramping up,
+// a fixed seed makes them repeatable in tests.
+// The source is only used by the circuit breaker and does not need to be safe for concurrent use
+func WithRandSource(src rand.Source) Option {
+	return func(c *CircuitBreaker) {
+		c.rand = rand.New(src)
+	}
+}
+
+// WithHealthMetricsWindow sets the size of the metrics window
+func WithHealthMetricsWindow(d time.Duration) Option {
+	return func(c *CircuitBreaker) {
+		c.config.HealthMetricsWindow = d
+	}
+}
+
+// WithHealthMetricsBucketSize sets the granularity of the metrics window, such as 100ms for a window that
+// reacts faster than a second
+func WithHealthMetricsBucketSize(d time.Duration) Option {
+	return func(c *CircuitBreaker) {
+		c.config.HealthMetricsBucketSize = d
+	}
+}
+
+// WithErrorPercentageThreshold sets the ratio of failures at which the circuit opens
+func WithErrorPercentageThreshold(threshold float64) Option {
+	return func(c *CircuitBreaker) {
+		c.config.HealthErrorPercentageThreshold = threshold
+	}
+}
+
+// WithTimeout sets the time an operation may run before it is abandoned and recorded as a timeout.
+// The operation is not stopped, so it should also watch the context given to DoWithContext
+func WithTimeout(d time.Duration) Option {
+	return func(c *CircuitBreaker) {
+		c.config.Timeout = d
+	}
+}
+
+// WithClock sets the source of the current time
+func WithClock(clk clock.Clock) Option {
+	return func(c *CircuitBreaker) {
+		c.config.Clock = clk
+	}
+}
+
+// WithStateChannel sets a channel that receives every state change
+func WithStateChannel(ch chan State) Option {
+	return func(c *CircuitBreaker) {
+		c.stateChan = ch
+	}
+}
+
+// WithFallback sets the function called instead of the operation while the circuit is open
+func WithFallback(fallback func() (interface{}, error)) Option {
+	return func(c *CircuitBreaker) {
+		c.fallback = fallback
+	}
+}
+
+// WithHealthPolicy sets the policy used by the default health window
+func WithHealthPolicy(policy health.HealthPolicy) Option {
+	return func(c *CircuitBreaker) {
+		c.policy = policy
+	}
+}
+
+// WithHealth replaces the default health window with any Health implementation
+func WithHealth(h Health) Option {
+	return func(c *CircuitBreaker) {
+		c.health = h
+	}
+}
+
+// WithEWMAHealth replaces the default health window with an EWMA of the failure rate and latency,
+// using the error percentage threshold and clock of the final Config
+func WithEWMAHealth(halfLife, latencyThreshold time.Duration) Option {
+	return func(c *CircuitBreaker) {
+		c.ewma = &health.EWMAConfig{
+			HalfLife:         halfLife,
+			LatencyThreshold: latencyThreshold,
+		}
+	}
+}
+
+// WithShadowMode runs every call even when the circuit would reject it, so a new breaker can be observed before it
+// is enforced. Transitions are reported as WouldOpen and WouldClose events instead of being sent to the state channel,
+// and the rejected calls are counted in Snapshot.WouldReject
+func WithShadowMode() Option {
+	return func(c *CircuitBreaker) {
+		c.shadow = true
+	}
+}
@@ -0,0 +1,105 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestNew(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name       string
+		breaker    string
+		opts       []Option
+		wantConfig Config
+		wantErr    bool
+	}{
+		{
+			name:    "uses the default config",
+			breaker: "test",
+			opts: []Option{
+				WithClock(clock),
+			},
+			wantConfig: Config{
+				SleepWindow:                    DefaultSleepWindow,
+				HealthMetricsWindow:            DefaultHealthMetricsWindow,
+				HealthErrorPercentageThreshold: DefaultHealthErrorPercentageThreshold,
+				Clock:                          clock,
+			},
+		},
+		{
+			name:    "applies every option",
+			breaker: "test",
+			opts: []Option{
+				WithSleepWindow(2 * time.Second),
+				WithHealthMetricsWindow(time.Minute),
+				WithHealthMetricsBucketSize(100 * time.Millisecond),
+				WithErrorPercentageThreshold(0.25),
+				WithClock(clock),
+			},
+			wantConfig: Config{
+				SleepWindow:                    2 * time.Second,
+				HealthMetricsWindow:            time.Minute,
+				HealthMetricsBucketSize:        100 * time.Millisecond,
+				HealthErrorPercentageThreshold: 0.25,
+				Clock:                          clock,
+			},
+		},
+		{
+			name:    "applies options in order",
+			breaker: "test",
+			opts: []Option{
+				WithSleepWindow(2 * time.Second),
+				WithConfig(Config{
+					SleepWindowMillisenconds: 3000,
+					HealthMetricsWindowSize:  5,
+				}),
+				WithErrorPercentageThreshold(0.25),
+				WithClock(clock),
+			},
+			wantConfig: Config{
+				SleepWindowMillisenconds:       3000,
+				HealthMetricsWindowSize:        5,
+				HealthErrorPercentageThreshold: 0.25,
+				Clock:                          clock,
+			},
+		},
+		{
+			name:    "rejects an empty name",
+			breaker: "",
+			wantErr: true,
+		},
+		{
+			name:    "rejects an invalid config",
+			breaker: "test",
+			opts: []Option{
+				WithErrorPercentageThreshold(5),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.breaker, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if c.Name() != tt.breaker {
+				t.Errorf("New() name = %v, want %v", c.Name(), tt.breaker)
+			}
+			if c.config != tt.wantConfig {
+				t.Errorf("New() config = %+v, want %+v", c.config, tt.wantConfig)
+			}
+			if c.Status() != Closed {
+				t.Errorf("New() status = %v, want %v", c.Status(), Closed)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// Operation is a call made through a Policy. It should give up once ctx is done
+type Operation func(ctx context.Context) (interface{}, error)
+
+// Policy runs an operation with some resilience behaviour, such as retries or a timeout
+type Policy interface {
+	Execute(ctx context.Context, operation Operation) (interface{}, error)
+}
+
+// PolicyFunc is an adapter to allow the use of ordinary functions as a Policy
+type PolicyFunc func(ctx context.Context, operation Operation) (interface{}, error)
+
+// Execute ...
+func (f PolicyFunc) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	return f(ctx, operation)
+}
+
+// Wrap stacks policies into one. The first policy is the outermost, so
+//
+//	Wrap(fallback, retry, breaker, timeout)
+//
+// times out each attempt, records every attempt with the breaker, retries failed attempts
+// and falls back once the retries are exhausted
+func Wrap(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx context.Context, operation Operation) (interface{}, error) {
+		for i := len(policies) - 1; i >= 0; i-- {
+			policy, next := policies[i], operation
+			operation = func(ctx context.Context) (interface{}, error) {
+				return policy.Execute(ctx, next)
+			}
+		}
+		return operation(ctx)
+	})
+}
+
+// Execute runs operation through the circuit breaker. The context passed to the operation is cancelled
+// once the breaker abandons it after a timeout
+func (c *CircuitBreaker) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	return c.run(ctx, operation, c.fallback)
+}
+
+// executeOrReject is Execute returning a CircuitOpenError for the calls the circuit does not admit, whatever the
+// fallback of the breaker, so that callers with a fallback of their own can tell an open circuit from a result
+func (c *CircuitBreaker) executeOrReject(ctx context.Context, operation Operation) (interface{}, error) {
+	return c.run(ctx, operation, defaultFallback)
+}
+
+// run is Execute calling fallback for the calls the circuit does not admit
+func (c *CircuitBreaker) run(ctx context.Context, operation Operation, fallback func() (interface{}, error)) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	return c.do(ctx, func() (interface{}, error) {
+		return operation(ctx)
+	}, fallback)
+}
+
+// executeAdmitted runs operation once ac admits it
+func executeAdmitted(ctx context.Context, ac AdmissionController, operation Operation) (interface{}, error) {
+	release, err := ac.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return operation(ctx)
+}
+
+// Execute runs operation once the bulkhead admits it, or returns a RejectedError
+func (b *Bulkhead) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	return executeAdmitted(ctx, b, operation)
+}
+
+// Execute runs operation once the rate limiter admits it, or returns a RejectedError
+func (l *RateLimiter) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	return executeAdmitted(ctx, l, operation)
+}
+
+// Execute runs operation once the limiter admits it, or returns a RejectedError.
+// Without a circuit breaker reporting the outcome the limit does not change
+func (l *AdaptiveLimiter) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	return executeAdmitted(ctx, l, operation)
+}
+
+// TimeoutPolicy abandons an operation that runs longer than Timeout, cancelling its context and returning a TimeoutError
+type TimeoutPolicy struct {
+	Timeout time.Duration
+
+	// the source of the timer, defaults to the system clock
+	Clock clock.Clock
+}
+
+// Execute ...
+func (p TimeoutPolicy) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	if p.Timeout <= 0 {
+		return operation(ctx)
+	}
+
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.New()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := operation(ctx)
+		done <- outcome{result, err}
+	}()
+
+	timer := clk.NewTimer(p.Timeout)
+	defer timer.Stop()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-timer.C():
+		return nil, &TimeoutError{Timeout: p.Timeout}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// DefaultRetryAttempts is the number of attempts made by a RetryPolicy when Attempts is not set
+const DefaultRetryAttempts = 3
+
+// RetryPolicy runs an operation again after a failed attempt, returning the error of the last attempt
+type RetryPolicy struct {
+	// the number of attempts including the first, defaults to DefaultRetryAttempts
+	Attempts int
+
+	// the wait before each retry, the first retry is attempt 1. Defaults to ExponentialBackoff(100ms, 10s)
+	Backoff func(attempt int) time.Duration
+
+	// whether an error is worth retrying, defaults to Retryable
+	Retryable func(err error) bool
+
+	// the source of the backoff timers, defaults to the system clock
+	Clock clock.Clock
+}
+
+// Execute ...
+func (p RetryPolicy) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	attempts := p.Attempts
+	if attempts <= 0 {
+		attempts = DefaultRetryAttempts
+	}
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = Retryable
+	}
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.New()
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := operation(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) || ctx.Err() != nil {
+			return result, err
+		}
+
+		timer := clk.NewTimer(backoff(attempt))
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		}
+	}
+}
+
+// Retryable reports whether an error may succeed on another attempt. Open circuits, rejected calls and
+// errors of a context that is done are not retried, including context errors wrapped by an Unwrap method
+func Retryable(err error) bool {
+	switch err.(type) {
+	case *CircuitOpenError, *RejectedError:
+		return false
+	}
+	return !contextError(err)
+}
+
+// contextError reports whether err is, or wraps, the error of a context that is done
+func contextError(err error) bool {
+	for err != nil {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return true
+		}
+
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = wrapper.Unwrap()
+	}
+	return false
+}
+
+// ExponentialBackoff returns a backoff doubling from base with every retry, capped at max
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			return max
+		}
+		return d
+	}
+}
+
+// FallbackPolicy answers a failed operation with Fallback, which receives the error of the operation
+type FallbackPolicy struct {
+	Fallback func(ctx context.Context, err error) (interface{}, error)
+}
+
+// Execute ...
+func (p FallbackPolicy) Execute(ctx context.Context, operation Operation) (interface{}, error) {
+	result, err := operation(ctx)
+	if err == nil {
+		return result, nil
+	}
+	return p.Fallback(ctx, err)
+}
@@ -0,0 +1,166 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestWrap(t *testing.T) {
+	var calls []string
+	trace := func(name string) Policy {
+		return PolicyFunc(func(ctx context.Context, operation Operation) (interface{}, error) {
+			calls = append(calls, name)
+			return operation(ctx)
+		})
+	}
+
+	got, err := Wrap(trace("outer"), trace("inner")).Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		calls = append(calls, "operation")
+		return 100, nil
+	})
+	if err != nil || got != 100 {
+		t.Errorf("Wrap().Execute() = %v, %v, want %v", got, err, 100)
+	}
+	if want := []string{"outer", "inner", "operation"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Wrap().Execute() calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRetryPolicy_Execute(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	p := RetryPolicy{Attempts: 3, Backoff: ExponentialBackoff(time.Second, time.Minute), Clock: clock}
+
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		_, err := p.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+			attempts++
+			return nil, errors.New("failure")
+		})
+		done <- err
+	}()
+
+	// the retries wait for 1s and then 2s
+	for _, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		clock.BlockUntil(1)
+		clock.Advance(backoff)
+	}
+
+	if err := <-done; err == nil || err.Error() != "failure" {
+		t.Errorf("RetryPolicy.Execute() error = %v, want %v", err, "failure")
+	}
+	if attempts != 3 {
+		t.Errorf("RetryPolicy.Execute() made %v attempts, want %v", attempts, 3)
+	}
+}
+
+func TestRetryPolicy_Execute_NotRetryable(t *testing.T) {
+	c, _ := New("test")
+	c.SetStatus(ForcedOpen)
+
+	attempts := 0
+	_, err := Wrap(RetryPolicy{}, c).Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		attempts++
+		return 100, nil
+	})
+	if _, ok := err.(*CircuitOpenError); !ok {
+		t.Errorf("Wrap().Execute() error = %v, want %T", err, &CircuitOpenError{})
+	}
+	if attempts != 0 {
+		t.Errorf("Wrap().Execute() made %v attempts through an open circuit, want 0", attempts)
+	}
+}
+
+// wrappedError wraps another error the way errors created with %w do
+type wrappedError struct {
+	err error
+}
+
+func (e *wrappedError) Error() string {
+	return "wrapped: " + e.err.Error()
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "failure", err: errors.New("failure"), want: true},
+		{name: "open circuit", err: &CircuitOpenError{}, want: false},
+		{name: "rejected call", err: &RejectedError{Reason: "full"}, want: false},
+		{name: "cancelled context", err: context.Canceled, want: false},
+		{name: "expired context", err: context.DeadlineExceeded, want: false},
+		{name: "wrapped context error", err: &wrappedError{&wrappedError{context.Canceled}}, want: false},
+		{name: "wrapped failure", err: &wrappedError{errors.New("failure")}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutPolicy_Execute(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	p := TimeoutPolicy{Timeout: time.Second, Clock: clock}
+
+	cancelled := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := p.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		})
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	if err := <-done; !reflect.DeepEqual(err, &TimeoutError{Timeout: time.Second}) {
+		t.Errorf("TimeoutPolicy.Execute() error = %v, want %v", err, &TimeoutError{Timeout: time.Second})
+	}
+	<-cancelled
+}
+
+func TestFallbackPolicy_Execute(t *testing.T) {
+	p := FallbackPolicy{Fallback: func(ctx context.Context, err error) (interface{}, error) {
+		return err.Error(), nil
+	}}
+
+	got, err := Wrap(p, NewBulkhead(1, 0)).Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("failure")
+	})
+	if err != nil || got != "failure" {
+		t.Errorf("FallbackPolicy.Execute() = %v, %v, want %v", got, err, "failure")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(100*time.Millisecond, time.Second)
+
+	for attempt, want := range map[int]time.Duration{
+		1:   100 * time.Millisecond,
+		2:   200 * time.Millisecond,
+		4:   800 * time.Millisecond,
+		5:   time.Second,
+		100: time.Second,
+	} {
+		if got := backoff(attempt); got != want {
+			t.Errorf("ExponentialBackoff()(%v) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// prober checks an open circuit in the background
+type prober struct {
+	probe    func(ctx context.Context) error
+	interval time.Duration
+
+	// stop ends the probes, done is closed once they have ended
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// WithProbe checks the dependency with probe every interval while the circuit is Open or HalfOpen, so that recovery
+// does not have to be discovered by a real call. A successful probe moves an Open circuit to HalfOpen and a HalfOpen
+// circuit to Closed, a failed probe moves a HalfOpen circuit back to Open. Only the probes move the circuit out of Open:
+// calls are rejected while it is Open or HalfOpen, whether or not the sleep window has elapsed.
+// Probes are not recorded in the health window and run with the Timeout of the config. Close stops them
+func WithProbe(interval time.Duration, probe func(ctx context.Context) error) Option {
+	return func(c *CircuitBreaker) {
+		c.prober = &prober{
+			probe:    probe,
+			interval: interval,
+		}
+	}
+}
+
+// startProbes runs the probes until Close is called
+func (c *CircuitBreaker) startProbes() error {
+	if c.prober.interval <= 0 {
+		return errors.New("probe interval must be positive")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.prober.stop = cancel
+	c.prober.done = make(chan struct{})
+
+	ticker := c.config.Clock.NewTicker(c.prober.interval)
+	go c.runProbes(ctx, ticker)
+
+	return nil
+}
+
+func (c *CircuitBreaker) runProbes(ctx context.Context, ticker clock.Ticker) {
+	defer close(c.prober.done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			c.runProbe(ctx)
+		}
+	}
+}
+
+// runProbe probes an Open or HalfOpen circuit and moves it on the result.
+// The result is ignored when the state has changed while the probe ran
+func (c *CircuitBreaker) runProbe(ctx context.Context) {
+	c.mu.Lock()
+	state := c.state
+	timeout := c.config.Timeout
+	c.mu.Unlock()
+
+	if state.status != Open && state.status != HalfOpen {
+		return
+	}
+
+	probeCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		probeCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := c.prober.probe(probeCtx)
+
+	// the circuit breaker was closed while the probe ran
+	if ctx.Err() != nil {
+		return
+	}
+
+	if state.status == HalfOpen {
+		c.probed(state, err == nil)
+		return
+	}
+
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == state {
+		c.setStatus(HalfOpen)
+	}
+}
+
+// Close stops the background probes and waits for a running probe to return
+func (c *CircuitBreaker) Close() error {
+	if c.prober == nil {
+		return nil
+	}
+
+	c.prober.stop()
+	<-c.prober.done
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestCircuitBreaker_WithProbe(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	events := make(chan Event)
+	results := make(chan error)
+
+	c, err := New("test",
+		WithSleepWindow(time.Minute),
+		WithClock(clock),
+		WithEventChannel(events),
+		WithProbe(time.Second, func(ctx context.Context) error {
+			return <-results
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+
+	c.SetStatus(Open)
+	<-events
+
+	// the probes wait for the first tick
+	clock.BlockUntil(1)
+
+	steps := []struct {
+		result error
+		want   Status
+	}{
+		// a failed probe leaves an open circuit as it is
+		{result: errors.New("failure")},
+		{result: nil, want: HalfOpen},
+		{result: errors.New("failure"), want: Open},
+		{result: nil, want: HalfOpen},
+		{result: nil, want: Closed},
+	}
+	for _, step := range steps {
+		clock.Advance(time.Second)
+		results <- step.result
+
+		if step.want == 0 {
+			continue
+		}
+
+		event := <-events
+		if got := event.State.Status(); got != step.want {
+			t.Fatalf("CircuitBreaker probe moved the circuit to %v, want %v", got, step.want)
+		}
+	}
+
+	if got := c.Status(); got != Closed {
+		t.Errorf("CircuitBreaker.Status() = %v, want %v", got, Closed)
+	}
+	if got := c.health.Stats().Requests(); got != 0 {
+		t.Errorf("CircuitBreaker probe recorded %v requests, want 0", got)
+	}
+}
+
+func TestCircuitBreaker_WithProbe_RejectsCalls(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test",
+		WithSleepWindow(time.Second),
+		WithClock(clock),
+		WithProbe(time.Minute, func(ctx context.Context) error {
+			return nil
+		}),
+	)
+	defer c.Close()
+
+	calls := 0
+	operation := func() (interface{}, error) {
+		calls++
+		return 100, nil
+	}
+
+	// user calls never act as the canary, not even once the sleep window has elapsed
+	for _, status := range []Status{Open, HalfOpen} {
+		c.SetStatus(status)
+		clock.Advance(2 * time.Second)
+
+		if _, err := c.DoWithContext(context.Background(), operation); !reflect.DeepEqual(err, &CircuitOpenError{}) {
+			t.Errorf("CircuitBreaker.DoWithContext() error = %v while %v, want %v", err, status, &CircuitOpenError{})
+		}
+		if got := c.Status(); got != status {
+			t.Errorf("CircuitBreaker.DoWithContext() moved the circuit from %v to %v", status, got)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("CircuitBreaker.DoWithContext() called the operation %v times, want 0", calls)
+	}
+}
+
+func TestCircuitBreaker_Close(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithClock(clock), WithProbe(time.Second, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	c.SetStatus(Open)
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("CircuitBreaker.Close() error = %v", err)
+	}
+	if got := c.Status(); got != Open {
+		t.Errorf("CircuitBreaker.Status() = %v after Close, want %v", got, Open)
+	}
+
+	if _, err := New("test", WithProbe(0, nil)); err == nil {
+		t.Errorf("New() error = %v, wantErr %v", err, true)
+	}
+}
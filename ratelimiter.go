@@ -0,0 +1,156 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// RateLimiterConfig ...
+type RateLimiterConfig struct {
+	// the number of calls admitted per second on average
+	Rate float64
+
+	// the number of calls that may be admitted at once after a quiet period, at least 1
+	Burst int
+
+	// whether a call waits for a token until its context is done instead of being rejected straight away
+	Wait bool
+
+	// the source of the current time and of timers, defaults to the system clock
+	Clock clock.Clock
+}
+
+// RateLimiter is a token bucket AdmissionController. The bucket holds up to Burst tokens and
+// is refilled at Rate tokens per second, every call takes a token
+type RateLimiter struct {
+	mu      sync.Mutex
+	config  RateLimiterConfig
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns a RateLimiter with a full bucket
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+
+	return &RateLimiter{
+		config:  config,
+		tokens:  float64(config.Burst),
+		updated: config.Clock.Now(),
+	}
+}
+
+// WithRateLimit limits calls with a RateLimiter using the clock of the circuit breaker.
+// Calls it rejects are recorded as health.Rejection and answered by the fallback
+func WithRateLimit(rate float64, burst int, wait bool) Option {
+	return func(c *CircuitBreaker) {
+		c.admissionBuilders = append(c.admissionBuilders, func(c *CircuitBreaker) AdmissionController {
+			return NewRateLimiter(RateLimiterConfig{
+				Rate:  rate,
+				Burst: burst,
+				Wait:  wait,
+				Clock: c.config.Clock,
+			})
+		})
+	}
+}
+
+// Acquire takes a token. Without one the call is rejected, or waits for the next token when Wait is set.
+// A waiting call whose context is done returns its token and the context error
+func (l *RateLimiter) Acquire(ctx context.Context) (func(), error) {
+	delay, ok := l.reserve()
+	if !ok {
+		return nil, &RejectedError{Reason: "rate limit exceeded"}
+	}
+	if delay == 0 {
+		return func() {}, nil
+	}
+
+	var ready <-chan time.Time
+	if delay > 0 {
+		timer := l.config.Clock.NewTimer(delay)
+		defer timer.Stop()
+		ready = timer.C()
+	}
+
+	select {
+	case <-ready:
+		return func() {}, nil
+	case <-ctx.Done():
+		l.cancel()
+		return nil, ctx.Err()
+	}
+}
+
+// Tokens returns the number of tokens in the bucket, negative while calls are waiting for tokens
+func (l *RateLimiter) Tokens() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	return l.tokens
+}
+
+// reserve takes a token, returning how long to wait until it is available.
+// A negative delay means the bucket is never refilled
+func (l *RateLimiter) reserve() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	if !l.config.Wait {
+		return 0, false
+	}
+
+	l.tokens--
+	if l.config.Rate <= 0 {
+		return -1, true
+	}
+
+	// wait until the token taken is refilled, at least 1ns
+	delay := time.Duration(-l.tokens / l.config.Rate * float64(time.Second))
+	if delay <= 0 {
+		delay = 1
+	}
+	return delay, true
+}
+
+// cancel returns the token of a call that stopped waiting
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	l.tokens++
+	if max := float64(l.config.Burst); l.tokens > max {
+		l.tokens = max
+	}
+}
+
+// refill adds the tokens accrued since the last update. l.mu must be held
+func (l *RateLimiter) refill() {
+	now := l.config.Clock.Now()
+	elapsed := now.Sub(l.updated)
+	l.updated = now
+
+	if elapsed <= 0 || l.config.Rate <= 0 {
+		return
+	}
+
+	l.tokens += elapsed.Seconds() * l.config.Rate
+	if max := float64(l.config.Burst); l.tokens > max {
+		l.tokens = max
+	}
+}
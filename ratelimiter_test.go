@@ -0,0 +1,104 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestRateLimiter_Acquire(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := NewRateLimiter(RateLimiterConfig{Rate: 2, Burst: 3, Clock: clock})
+
+	admitted := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if _, err := l.Acquire(context.Background()); err == nil {
+				n++
+			} else if _, ok := err.(*RejectedError); !ok {
+				t.Fatalf("RateLimiter.Acquire() error = %v, want %T", err, &RejectedError{})
+			}
+		}
+		return n
+	}
+
+	if got := admitted(); got != 3 {
+		t.Errorf("RateLimiter.Acquire() admitted %v calls from a full bucket, want the burst of %v", got, 3)
+	}
+
+	clock.Advance(time.Second)
+	if got := admitted(); got != 2 {
+		t.Errorf("RateLimiter.Acquire() admitted %v calls after a second, want the rate of %v", got, 2)
+	}
+
+	// the bucket holds no more than the burst
+	clock.Advance(time.Minute)
+	if got := admitted(); got != 3 {
+		t.Errorf("RateLimiter.Acquire() admitted %v calls after a minute, want the burst of %v", got, 3)
+	}
+}
+
+func TestRateLimiter_Acquire_Wait(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := NewRateLimiter(RateLimiterConfig{Rate: 2, Burst: 1, Wait: true, Clock: clock})
+
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("RateLimiter.Acquire() error = %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Acquire(context.Background())
+		done <- err
+	}()
+
+	// the next token is half a second away
+	clock.BlockUntil(1)
+	clock.Advance(499 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("RateLimiter.Acquire() returned %v before a token was available", err)
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("RateLimiter.Acquire() error = %v", err)
+	}
+
+	// a call that gives up waiting returns its token
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, err := l.Acquire(ctx)
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("RateLimiter.Acquire() error = %v, want %v", err, context.Canceled)
+	}
+	if got := l.Tokens(); got != 0 {
+		t.Errorf("RateLimiter.Tokens() = %v, want %v", got, 0)
+	}
+}
+
+func TestCircuitBreaker_DoWithContext_RateLimit(t *testing.T) {
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	c, _ := New("test", WithRateLimit(1, 2, false), WithBulkhead(10, 0), WithClock(clock))
+
+	for i := 0; i < 5; i++ {
+		c.DoWithContext(context.Background(), func() (interface{}, error) {
+			return 100, nil
+		})
+	}
+
+	stats := c.health.Stats()
+	if stats.Successes != 2 || stats.Rejections != 3 {
+		t.Errorf("CircuitBreaker.DoWithContext() stats = %+v, want 2 successes and 3 rejections", stats)
+	}
+	if got := c.Snapshot().InFlight; got != 0 {
+		t.Errorf("CircuitBreaker.Snapshot() in flight = %v, want %v", got, 0)
+	}
+}
@@ -0,0 +1,73 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry holds circuit breakers by name
+type Registry struct {
+	mu       sync.RWMutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewRegistry ...
+func NewRegistry() *Registry {
+	return &Registry{
+		breakers: map[string]*CircuitBreaker{},
+	}
+}
+
+// Register adds a circuit breaker, its name must not already be registered
+func (r *Registry) Register(c *CircuitBreaker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.breakers[c.Name()]; ok {
+		return fmt.Errorf("circuit breaker %q is already registered", c.Name())
+	}
+
+	r.breakers[c.Name()] = c
+	return nil
+}
+
+// Get ...
+func (r *Registry) Get(name string) (*CircuitBreaker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.breakers[name]
+	return c, ok
+}
+
+// Names returns the sorted names of every registered circuit breaker
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.breakers))
+	for name := range r.breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// List returns every registered circuit breaker sorted by name
+func (r *Registry) List() []*CircuitBreaker {
+	names := r.Names()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	breakers := make([]*CircuitBreaker, 0, len(names))
+	for _, name := range names {
+		if c, ok := r.breakers[name]; ok {
+			breakers = append(breakers, c)
+		}
+	}
+
+	return breakers
+}
@@ -0,0 +1,38 @@
+package circuitbreaker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	search, _ := New("search")
+	payments, _ := New("payments")
+
+	for _, c := range []*CircuitBreaker{search, payments} {
+		if err := r.Register(c); err != nil {
+			t.Fatalf("Registry.Register() error = %v", err)
+		}
+	}
+
+	duplicate, _ := New("search")
+	if err := r.Register(duplicate); err == nil {
+		t.Errorf("Registry.Register() error = %v, wantErr %v", err, true)
+	}
+
+	if got, ok := r.Get("search"); !ok || got != search {
+		t.Errorf("Registry.Get() = %v, %v, want %v", got, ok, search)
+	}
+	if _, ok := r.Get("missing"); ok {
+		t.Errorf("Registry.Get() found a missing breaker")
+	}
+
+	if got, want := r.Names(), []string{"payments", "search"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Registry.Names() = %v, want %v", got, want)
+	}
+	if got, want := r.List(), []*CircuitBreaker{payments, search}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Registry.List() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package circuitbreaker
+
+import (
+	"sync/atomic"
+	"time"
+
+	"circuitbreaker/health"
+)
+
+// Snapshot is a point-in-time view of a circuit breaker
+type Snapshot struct {
+	Name   string
+	State  State
+	Config Config
+	Stats  health.Summary
+
+	// the time an open circuit waits before probing, including backoff and jitter
+	SleepWindow time.Duration
+
+	// the fraction of calls admitted, below 1 while the circuit ramps up after closing
+	RampUp float64
+
+	// the calls holding and waiting for a slot of the AdmissionControllers that report them
+	InFlight int64
+	Queued   int64
+
+	// Shadow is set for a circuit in shadow mode, WouldReject counts the calls it would have rejected
+	Shadow      bool
+	WouldReject int64
+}
+
+// Snapshot ...
+func (c *CircuitBreaker) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	inFlight, queued := c.admissionStats()
+
+	return Snapshot{
+		Name:   c.name,
+		State:  c.state,
+		Config: c.config,
+		Stats:  c.health.Stats(),
+
+		SleepWindow: c.sleepWindow(),
+		RampUp:      c.rampUp(c.config.Clock.Now()),
+
+		InFlight: inFlight,
+		Queued:   queued,
+
+		Shadow:      c.shadow,
+		WouldReject: atomic.LoadInt64(&c.wouldReject),
+	}
+}
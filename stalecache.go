@@ -0,0 +1,140 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// StaleCacheConfig ...
+type StaleCacheConfig struct {
+	// how long a stored result is served without calling the operation, zero always calls it
+	TTL time.Duration
+
+	// how long after the TTL a stored result may still be served when the call fails, zero never serves stale results
+	MaxStaleness time.Duration
+
+	// the source of the current time, defaults to the system clock
+	Clock clock.Clock
+}
+
+// CacheResult is a response served by a StaleCache
+type CacheResult struct {
+	Value interface{}
+
+	// set when the call failed and the value is older than the TTL
+	Stale bool
+
+	// the time the value was stored, zero for a value the operation has just returned
+	StoredAt time.Time
+}
+
+// StaleCache stores the last successful result of a circuit breaker for each key and serves it while the circuit
+// is open or the call fails. The fallback of the breaker is not called
+type StaleCache struct {
+	breaker *CircuitBreaker
+	config  StaleCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	purged  time.Time
+}
+
+type cacheEntry struct {
+	value    interface{}
+	storedAt time.Time
+}
+
+// NewStaleCache ...
+func NewStaleCache(breaker *CircuitBreaker, config StaleCacheConfig) *StaleCache {
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+
+	return &StaleCache{
+		breaker: breaker,
+		config:  config,
+		entries: map[string]cacheEntry{},
+		purged:  config.Clock.Now(),
+	}
+}
+
+// Do returns the result stored for key while it is younger than the TTL. Otherwise it runs operation through the
+// circuit breaker, storing a successful result, and falls back to the stored result while it is younger than the TTL
+// plus MaxStaleness. The error of the call is returned when there is no such result
+func (s *StaleCache) Do(ctx context.Context, key string, operation Operation) (CacheResult, error) {
+	now := s.config.Clock.Now()
+
+	entry, ok := s.get(key, now)
+	if ok && now.Sub(entry.storedAt) < s.config.TTL {
+		return CacheResult{Value: entry.value, StoredAt: entry.storedAt}, nil
+	}
+
+	value, err := s.breaker.executeOrReject(ctx, operation)
+	if err == nil {
+		s.put(key, cacheEntry{value: value, storedAt: s.config.Clock.Now()})
+		return CacheResult{Value: value}, nil
+	}
+
+	// a caller that gave up does not need a response
+	if !ok || ctx.Err() != nil {
+		return CacheResult{}, err
+	}
+
+	return CacheResult{
+		Value:    entry.value,
+		Stale:    now.Sub(entry.storedAt) >= s.config.TTL,
+		StoredAt: entry.storedAt,
+	}, nil
+}
+
+// Invalidate removes the result stored for key
+func (s *StaleCache) Invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+// get returns the result stored for key unless it is too old to be served
+func (s *StaleCache) get(key string, now time.Time) (cacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if ok && s.expired(entry, now) {
+		delete(s.entries, key)
+		return cacheEntry{}, false
+	}
+	return entry, ok
+}
+
+// put stores a result, removing every expired result once per lifetime of a result
+func (s *StaleCache) put(key string, entry cacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = entry
+
+	if entry.storedAt.Sub(s.purged) < s.lifetime() {
+		return
+	}
+	for k, e := range s.entries {
+		if s.expired(e, entry.storedAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.purged = entry.storedAt
+}
+
+// lifetime is how long a result may be served for
+func (s *StaleCache) lifetime() time.Duration {
+	return s.config.TTL + s.config.MaxStaleness
+}
+
+// expired determines whether a result is too old to be served. s.mu must be held
+func (s *StaleCache) expired(entry cacheEntry, now time.Time) bool {
+	return now.Sub(entry.storedAt) >= s.lifetime()
+}
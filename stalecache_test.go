@@ -0,0 +1,151 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestStaleCache_Do(t *testing.T) {
+	start := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := clocktest.NewClock(start)
+	c, _ := New("test", WithClock(clock), WithFallback(func() (interface{}, error) {
+		return "fallback", nil
+	}))
+	s := NewStaleCache(c, StaleCacheConfig{TTL: time.Minute, MaxStaleness: 10 * time.Minute, Clock: clock})
+
+	calls := 0
+	var failure error
+	operation := func(ctx context.Context) (interface{}, error) {
+		calls++
+		if failure != nil {
+			return nil, failure
+		}
+		return calls, nil
+	}
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		status    Status
+		failure   error
+		wantCalls int
+		want      CacheResult
+		wantErr   bool
+	}{
+		{
+			name:      "stores a successful result",
+			wantCalls: 1,
+			want:      CacheResult{Value: 1},
+		},
+		{
+			name:      "serves a fresh result without calling",
+			advance:   30 * time.Second,
+			wantCalls: 1,
+			want:      CacheResult{Value: 1, StoredAt: start},
+		},
+		{
+			name:      "serves a stale result when the call fails",
+			advance:   time.Minute,
+			failure:   errors.New("connection refused"),
+			wantCalls: 2,
+			want:      CacheResult{Value: 1, Stale: true, StoredAt: start},
+		},
+		{
+			name:      "serves a stale result when the circuit is open",
+			status:    ForcedOpen,
+			wantCalls: 2,
+			want:      CacheResult{Value: 1, Stale: true, StoredAt: start},
+		},
+		{
+			name:      "replaces the result once the call succeeds",
+			status:    ForcedClosed,
+			wantCalls: 3,
+			want:      CacheResult{Value: 3},
+		},
+		{
+			name:      "returns the error after the max staleness",
+			advance:   11 * time.Minute,
+			status:    ForcedOpen,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+	}
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		if step.status != 0 {
+			c.SetStatus(step.status)
+		}
+		failure = step.failure
+
+		got, err := s.Do(context.Background(), "key", operation)
+		if step.wantErr {
+			if _, ok := err.(*CircuitOpenError); !ok {
+				t.Errorf("%s: StaleCache.Do() error = %v, want %T", step.name, err, &CircuitOpenError{})
+			}
+		} else if err != nil {
+			t.Errorf("%s: StaleCache.Do() error = %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: StaleCache.Do() = %+v, want %+v", step.name, got, step.want)
+		}
+		if calls != step.wantCalls {
+			t.Errorf("%s: StaleCache.Do() calls = %v, want %v", step.name, calls, step.wantCalls)
+		}
+	}
+}
+
+func TestStaleCache_Do_Cancelled(t *testing.T) {
+	c, _ := New("test")
+	s := NewStaleCache(c, StaleCacheConfig{TTL: time.Nanosecond, MaxStaleness: time.Hour})
+
+	s.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return 100, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a caller that gave up gets the error of its call rather than a stale result
+	got, err := s.Do(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		return nil, fmt.Errorf("calling the dependency: %v", ctx.Err())
+	})
+	if err == nil || got != (CacheResult{}) {
+		t.Errorf("StaleCache.Do() = %+v, %v, want the error of the cancelled call", got, err)
+	}
+}
+
+func TestStaleCache_Do_Keys(t *testing.T) {
+	c, _ := New("test")
+	s := NewStaleCache(c, StaleCacheConfig{MaxStaleness: time.Minute})
+
+	if _, err := s.Do(context.Background(), "a", func(ctx context.Context) (interface{}, error) {
+		return "a", nil
+	}); err != nil {
+		t.Fatalf("StaleCache.Do() error = %v", err)
+	}
+
+	failure := errors.New("connection refused")
+	fail := func(ctx context.Context) (interface{}, error) {
+		return nil, failure
+	}
+
+	// a key without a stored result gets the error
+	if _, err := s.Do(context.Background(), "b", fail); err != failure {
+		t.Errorf("StaleCache.Do() error = %v, want %v", err, failure)
+	}
+
+	// with no TTL a stored result is only served as a fallback, and always as stale
+	if got, err := s.Do(context.Background(), "a", fail); err != nil || got.Value != "a" || !got.Stale {
+		t.Errorf("StaleCache.Do() = %+v, %v, want a stale a", got, err)
+	}
+
+	s.Invalidate("a")
+	if _, err := s.Do(context.Background(), "a", fail); err == nil {
+		t.Errorf("StaleCache.Do() error = %v after Invalidate, wantErr %v", err, true)
+	}
+}
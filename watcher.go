@@ -0,0 +1,73 @@
+package circuitbreaker
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+
+	"circuitbreaker/clock"
+)
+
+// DefaultWatchInterval is how often a FileWatcher checks its file when Interval is not set
+const DefaultWatchInterval = 5 * time.Second
+
+// FileWatcher reloads a configuration file into a Registry whenever its contents change
+type FileWatcher struct {
+	Registry *Registry
+	Path     string
+
+	// how often the file is checked, defaults to DefaultWatchInterval
+	Interval time.Duration
+
+	// applied to every breaker, see Registry.Reload
+	Options []Option
+
+	// called with the result of every reload, including read errors
+	OnReload func(err error)
+
+	// the source of the ticker, defaults to the system clock
+	Clock clock.Clock
+}
+
+// Watch reloads the file immediately and then every time its contents change, until ctx is done.
+// A file that fails to load leaves the registry unchanged and is retried once it changes again
+func (w *FileWatcher) Watch(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	clk := w.Clock
+	if clk == nil {
+		clk = clock.New()
+	}
+
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		data, err := ioutil.ReadFile(w.Path)
+		switch {
+		case err != nil:
+			last = nil
+			w.report(err)
+		case last == nil || !bytes.Equal(data, last):
+			last = data
+			w.report(w.Registry.Reload(w.Path, data, w.Options...))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+func (w *FileWatcher) report(err error) {
+	if w.OnReload != nil {
+		w.OnReload(err)
+	}
+}
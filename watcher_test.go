@@ -0,0 +1,79 @@
+package circuitbreaker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"circuitbreaker/clock/clocktest"
+)
+
+func TestFileWatcher_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "circuitbreaker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "breakers.yaml")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("breakers:\n  payments:\n    window: 30s\n")
+
+	clock := clocktest.NewClock(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC))
+	reloads := make(chan error)
+	r := NewRegistry()
+	w := &FileWatcher{
+		Registry: r,
+		Path:     path,
+		Interval: time.Second,
+		OnReload: func(err error) { reloads <- err },
+		Clock:    clock,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Watch(ctx)
+	}()
+
+	if err := <-reloads; err != nil {
+		t.Fatalf("FileWatcher.Watch() reload error = %v", err)
+	}
+	payments, ok := r.Get("payments")
+	if !ok {
+		t.Fatalf("FileWatcher.Watch() did not load payments")
+	}
+
+	write("breakers:\n  payments:\n    window: 1m\n")
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	if err := <-reloads; err != nil {
+		t.Fatalf("FileWatcher.Watch() reload error = %v", err)
+	}
+	if got := payments.Config().HealthMetricsWindow; got != time.Minute {
+		t.Errorf("FileWatcher.Watch() window = %v, want %v", got, time.Minute)
+	}
+
+	write("breakers:\n  payments:\n    window: -1s\n")
+	clock.Advance(time.Second)
+
+	if err := <-reloads; err == nil {
+		t.Errorf("FileWatcher.Watch() reload error = %v, wantErr %v", err, true)
+	}
+	if got := payments.Config().HealthMetricsWindow; got != time.Minute {
+		t.Errorf("FileWatcher.Watch() window = %v after an invalid file, want %v", got, time.Minute)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("FileWatcher.Watch() error = %v, want %v", err, context.Canceled)
+	}
+}